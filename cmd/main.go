@@ -2,15 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
-	"time"
+	"net/http"
+	_ "time/tzdata"
 
 	"gopkg.in/telebot.v3"
 
+	"github.com/cupitman9/budget-bot/internal/api"
 	"github.com/cupitman9/budget-bot/internal/bot"
 	"github.com/cupitman9/budget-bot/internal/config"
 	"github.com/cupitman9/budget-bot/internal/logger"
+	"github.com/cupitman9/budget-bot/internal/metrics"
 	"github.com/cupitman9/budget-bot/internal/storage"
+	"github.com/cupitman9/budget-bot/internal/webapp"
 )
 
 func main() {
@@ -19,7 +24,7 @@ func main() {
 		log.Fatalf("error loading configuration: %v", err)
 	}
 
-	appLogger := logger.New(cfg.LogLevel)
+	appLogger := logger.New(cfg.LogLevel, cfg.LogFormat)
 
 	ctx := context.Background()
 	appStorage, err := storage.NewStorage(ctx, cfg.PostgresDSN)
@@ -28,17 +33,58 @@ func main() {
 	}
 	defer appStorage.Close()
 
+	if cfg.MetricsListen != "" {
+		metrics.RegisterPool(appStorage.Stat)
+		metrics.RegisterActiveSessions(bot.ActiveSessions)
+		metricsServer := metrics.NewServer(cfg.MetricsListen, appStorage.Ping, appLogger)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				appLogger.WithError(err).Fatal("error serving metrics")
+			}
+		}()
+		appLogger.WithField("addr", cfg.MetricsListen).Info("metrics server started")
+	}
+
 	botSettings := telebot.Settings{
 		Token:  cfg.BotToken,
-		Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
+		Poller: bot.NewPoller(cfg, appLogger),
 	}
 	botAPI, err := telebot.NewBot(botSettings)
 	if err != nil {
 		appLogger.WithError(err).Error("error creating bot instance")
 		return
 	}
+	if err := bot.PrepareMode(botAPI, cfg); err != nil {
+		appLogger.WithError(err).Error("error removing webhook")
+		return
+	}
+
+	if err := bot.RegisterHandlers(botAPI, cfg, appStorage, appLogger); err != nil {
+		appLogger.WithError(err).Error("error registering handlers")
+		return
+	}
+	bot.StartJobs(ctx, botAPI, appStorage, cfg.StatsTopCategories, appLogger)
+
+	if cfg.WebApp.PublicURL != "" {
+		webAppServer := webapp.NewServer(cfg.WebApp.Listen, cfg.BotToken, appStorage, bot.Allows, appLogger)
+		go func() {
+			if err := webAppServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				appLogger.WithError(err).Fatal("error serving web app")
+			}
+		}()
+		appLogger.WithField("addr", cfg.WebApp.Listen).Info("web app server started")
+	}
+
+	if cfg.APIListen != "" {
+		apiServer := api.NewServer(cfg.APIListen, appStorage, bot.Allows, appLogger)
+		go func() {
+			if err := apiServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				appLogger.WithError(err).Fatal("error serving API")
+			}
+		}()
+		appLogger.WithField("addr", cfg.APIListen).Info("API server started")
+	}
 
-	bot.RegisterHandlers(botAPI, appStorage, appLogger)
-	appLogger.Info("bot starting")
+	appLogger.WithField("mode", cfg.BotMode).Info("bot starting")
 	botAPI.Start()
 }
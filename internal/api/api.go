@@ -0,0 +1,77 @@
+// Package api implements the JSON API over transactions, categories, accounts and stats. The handlers are
+// shared by the REST API, authenticated with personal access tokens, and by the Mini App dashboard,
+// authenticated with Telegram initData, and book through the same storage code as the bot.
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+//go:embed openapi.yaml
+var openAPI []byte
+
+type userIDKey struct{}
+
+// Handler serves the API endpoints on behalf of the user put into the request context by WithUserID.
+type Handler struct {
+	storageInstance *storage.Storage
+	log             *logrus.Logger
+	// source is recorded in the audit log for the changes, one of the model.AuditSource constants.
+	source string
+}
+
+func NewHandler(storageInstance *storage.Storage, log *logrus.Logger, source string) *Handler {
+	return &Handler{storageInstance: storageInstance, log: log, source: source}
+}
+
+// Register mounts the endpoints under prefix, each wrapped in auth, which has to authenticate the request
+// and call WithUserID.
+func (h *Handler) Register(mux *http.ServeMux, prefix string, auth func(http.HandlerFunc) http.HandlerFunc) {
+	mux.HandleFunc("GET "+prefix+"/transactions", auth(h.handleListTransactions))
+	mux.HandleFunc("POST "+prefix+"/transactions", auth(h.handleCreateTransaction))
+	mux.HandleFunc("PUT "+prefix+"/transactions/{id}", auth(h.handleUpdateTransaction))
+	mux.HandleFunc("DELETE "+prefix+"/transactions/{id}", auth(h.handleDeleteTransaction))
+	mux.HandleFunc("GET "+prefix+"/categories", auth(h.handleListCategories))
+	mux.HandleFunc("GET "+prefix+"/accounts", auth(h.handleListAccounts))
+	mux.HandleFunc("GET "+prefix+"/stats", auth(h.handleStats))
+}
+
+// WithUserID returns the request with the authenticated user, whose ledger the handlers work on.
+func WithUserID(r *http.Request, userID int64) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userIDKey{}, userID))
+}
+
+// UserID returns the authenticated user of the request, or zero.
+func UserID(r *http.Request) int64 {
+	id, _ := r.Context().Value(userIDKey{}).(int64)
+	return id
+}
+
+// writer returns the storage for changes, which the audit log attributes to the user of the request.
+func (h *Handler) writer(r *http.Request) *storage.Storage {
+	return h.storageInstance.WithActor(UserID(r), h.source)
+}
+
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func WriteError(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, map[string]string{"error": message})
+}
+
+// internalError logs err and answers with a generic 500, so that storage details do not leak.
+func (h *Handler) internalError(w http.ResponseWriter, r *http.Request, err error) {
+	h.log.WithFields(logrus.Fields{"path": r.URL.Path, "user_id": UserID(r)}).WithError(err).
+		Error("error handling API request")
+	WriteError(w, http.StatusInternalServerError, "internal error")
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+// Prefix is the path the REST API is served under.
+const Prefix = "/api/v1"
+
+type server struct {
+	// tokenChatID returns the owner of the token with the hash, or pgx.ErrNoRows.
+	tokenChatID func(tokenHash []byte) (int64, error)
+	allows      func(userID int64) bool
+	log         *logrus.Logger
+}
+
+// NewServer returns the HTTP server of the REST API. Requests are authenticated with the personal access
+// tokens issued by /api_token, and only users that allows lets through may use the API. The OpenAPI
+// document is served without authentication at Prefix/openapi.yaml.
+func NewServer(
+	addr string,
+	storageInstance *storage.Storage,
+	allows func(userID int64) bool,
+	log *logrus.Logger,
+) *http.Server {
+	s := &server{tokenChatID: storageInstance.GetChatIDByAPIToken, allows: allows, log: log}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+Prefix+"/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(openAPI)
+	})
+	NewHandler(storageInstance, log, model.AuditSourceAPI).Register(mux, Prefix, s.authenticate)
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
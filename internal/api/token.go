@@ -0,0 +1,59 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// tokenBytes is the amount of randomness in a personal access token.
+const tokenBytes = 32
+
+// NewToken generates a personal access token. Only its hash is meant to be stored.
+func NewToken() (token string, hash []byte, err error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the SHA-256 hash under which a token is stored. Tokens are random, so a salt or a slow
+// hash would add nothing.
+func HashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// authenticate lets through requests carrying the token of a user the bot is open to, in the
+// "Authorization: Bearer <token>" header.
+func (s *server) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			WriteError(w, http.StatusUnauthorized, "missing token")
+			return
+		}
+		userID, err := s.tokenChatID(HashToken(token))
+		if errors.Is(err, pgx.ErrNoRows) {
+			WriteError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		if err != nil {
+			s.log.WithField("path", r.URL.Path).WithError(err).Error("error checking API token")
+			WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if !s.allows(userID) {
+			WriteError(w, http.StatusForbidden, "access denied")
+			return
+		}
+		next(w, WithUserID(r, userID))
+	}
+}
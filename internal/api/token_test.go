@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewToken(t *testing.T) {
+	token, hash, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 43 {
+		t.Errorf("token length = %d, want 43", len(token))
+	}
+	if !bytes.Equal(hash, HashToken(token)) {
+		t.Error("hash differs from HashToken(token)")
+	}
+	other, _, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == token {
+		t.Error("two tokens are equal")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	if !bytes.Equal(HashToken("abc"), HashToken("abc")) {
+		t.Error("hash is not stable")
+	}
+	if bytes.Equal(HashToken("abc"), HashToken("abd")) {
+		t.Error("different tokens have the same hash")
+	}
+	if len(HashToken("abc")) != 32 {
+		t.Errorf("hash length = %d, want 32", len(HashToken("abc")))
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	const (
+		goodToken    = "good"
+		blockedToken = "blocked"
+		brokenToken  = "broken"
+		owner        = int64(42)
+		blockedUser  = int64(7)
+	)
+	s := &server{
+		tokenChatID: func(tokenHash []byte) (int64, error) {
+			switch {
+			case bytes.Equal(tokenHash, HashToken(goodToken)):
+				return owner, nil
+			case bytes.Equal(tokenHash, HashToken(blockedToken)):
+				return blockedUser, nil
+			case bytes.Equal(tokenHash, HashToken(brokenToken)):
+				return 0, errors.New("connection refused")
+			default:
+				return 0, pgx.ErrNoRows
+			}
+		},
+		allows: func(userID int64) bool { return userID != blockedUser },
+		log:    &logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Level: logrus.PanicLevel},
+	}
+	handler := s.authenticate(func(w http.ResponseWriter, r *http.Request) {
+		if UserID(r) != owner {
+			t.Errorf("user ID = %d, want %d", UserID(r), owner)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"valid token", "Bearer " + goodToken, http.StatusNoContent},
+		{"missing header", "", http.StatusUnauthorized},
+		{"empty token", "Bearer ", http.StatusUnauthorized},
+		{"other scheme", "Basic " + goodToken, http.StatusUnauthorized},
+		{"lowercase scheme", "bearer " + goodToken, http.StatusUnauthorized},
+		{"unknown token", "Bearer unknown", http.StatusUnauthorized},
+		{"blocked user", "Bearer " + blockedToken, http.StatusForbidden},
+		{"storage error", "Bearer " + brokenToken, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, Prefix+"/transactions", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
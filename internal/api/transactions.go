@@ -0,0 +1,362 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 500
+	dateLayout      = "2006-01-02"
+)
+
+type transactionJSON struct {
+	ID          int64     `json:"id"`
+	Type        uint8     `json:"type"`
+	CategoryID  int64     `json:"category_id,omitempty"`
+	AccountID   int64     `json:"account_id"`
+	ToAccountID int64     `json:"to_account_id,omitempty"`
+	Amount      float64   `json:"amount"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// transactionInput is the body of POST /transactions and PUT /transactions/{id}. A zero CreatedAt books
+// the transaction now or keeps the time of an updated one.
+type transactionInput struct {
+	Type       uint8     `json:"type"`
+	CategoryID int64     `json:"category_id"`
+	AccountID  int64     `json:"account_id"`
+	Amount     float64   `json:"amount"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type categoryJSON struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Icon     string `json:"icon,omitempty"`
+	ParentID int64  `json:"parent_id,omitempty"`
+	Kind     uint8  `json:"kind"`
+}
+
+type accountJSON struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type categoryStatJSON struct {
+	CategoryID int64   `json:"category_id"`
+	Name       string  `json:"name"`
+	Type       uint8   `json:"type"`
+	Sum        float64 `json:"sum"`
+	Count      int     `json:"count"`
+}
+
+type dailyAmountJSON struct {
+	Day    string  `json:"day"`
+	Amount float64 `json:"amount"`
+}
+
+type statsJSON struct {
+	Income     float64            `json:"income"`
+	Expense    float64            `json:"expense"`
+	Categories []categoryStatJSON `json:"categories"`
+	Daily      []dailyAmountJSON  `json:"daily"`
+}
+
+func newTransactionJSON(t model.Transaction) transactionJSON {
+	return transactionJSON{
+		ID:          t.ID,
+		Type:        t.TransactionType,
+		CategoryID:  t.CategoryID,
+		AccountID:   t.AccountID,
+		ToAccountID: t.ToAccountID,
+		Amount:      t.Amount,
+		CreatedAt:   t.CreatedAt,
+	}
+}
+
+// handleListTransactions lists transactions, newest first. Query parameters: from and to (inclusive dates,
+// YYYY-MM-DD), type, category, account, limit and offset.
+func (h *Handler) handleListTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, to, err := parsePeriod(query.Get("from"), query.Get("to"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	transactionType, errType := parseOptionalInt(query.Get("type"))
+	categoryID, errCategory := parseOptionalInt(query.Get("category"))
+	accountID, errAccount := parseOptionalInt(query.Get("account"))
+	if err := errors.Join(errType, errCategory, errAccount); err != nil || transactionType < 0 || transactionType > 3 {
+		WriteError(w, http.StatusBadRequest, "type, category and account must be IDs")
+		return
+	}
+	filter := model.TransactionFilter{
+		From:            from,
+		To:              to,
+		TransactionType: uint8(transactionType),
+		CategoryID:      categoryID,
+		AccountID:       accountID,
+		Limit:           defaultPageSize,
+	}
+	if limit, err := parseOptionalInt(query.Get("limit")); err == nil && limit > 0 {
+		filter.Limit = int(min(limit, maxPageSize))
+	}
+	if offset, err := parseOptionalInt(query.Get("offset")); err == nil && offset > 0 {
+		filter.Offset = int(offset)
+	}
+
+	transactions, err := h.storageInstance.GetTransactions(UserID(r), filter)
+	if err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+	response := make([]transactionJSON, 0, len(transactions))
+	for _, t := range transactions {
+		response = append(response, newTransactionJSON(t))
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// handleCreateTransaction books an income or an expense the way the bot does, so it can be undone with
+// /undo. The account may be omitted when the user has a single one.
+func (h *Handler) handleCreateTransaction(w http.ResponseWriter, r *http.Request) {
+	chatID := UserID(r)
+	var input transactionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if input.AccountID == 0 {
+		accounts, err := h.storageInstance.GetAccountsByChatID(chatID)
+		if err != nil {
+			h.internalError(w, r, err)
+			return
+		}
+		if len(accounts) != 1 {
+			WriteError(w, http.StatusBadRequest, "account_id is required")
+			return
+		}
+		input.AccountID = accounts[0].ID
+	}
+
+	transaction := model.Transaction{ChatID: chatID}
+	input.apply(&transaction)
+	if transaction.CreatedAt.IsZero() {
+		transaction.CreatedAt = time.Now()
+	}
+	if err := h.storageInstance.ValidateTransaction(transaction); err != nil {
+		h.validationError(w, r, err)
+		return
+	}
+	id, err := h.writer(r).AddTransaction(transaction)
+	if err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+	transaction.ID = id
+	WriteJSON(w, http.StatusCreated, newTransactionJSON(transaction))
+}
+
+// handleUpdateTransaction changes an income or an expense. The category and the account have to belong
+// to the user and the category has to allow the transaction type.
+func (h *Handler) handleUpdateTransaction(w http.ResponseWriter, r *http.Request) {
+	chatID := UserID(r)
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid transaction id")
+		return
+	}
+	var input transactionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	transaction, err := h.storageInstance.GetTransactionByID(chatID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+	if transaction.TransactionType == model.TransactionTypeTransfer {
+		WriteError(w, http.StatusConflict, "transfers cannot be edited")
+		return
+	}
+
+	input.apply(&transaction)
+	if err := h.storageInstance.ValidateTransaction(transaction); err != nil {
+		h.validationError(w, r, err)
+		return
+	}
+	if err := h.writer(r).UpdateTransaction(transaction); err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, newTransactionJSON(transaction))
+}
+
+// handleDeleteTransaction removes a transaction of any type together with its goal contribution.
+func (h *Handler) handleDeleteTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid transaction id")
+		return
+	}
+	err = h.writer(r).DeleteTransaction(UserID(r), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (input transactionInput) apply(transaction *model.Transaction) {
+	transaction.TransactionType = input.Type
+	transaction.CategoryID = input.CategoryID
+	transaction.AccountID = input.AccountID
+	transaction.Amount = input.Amount
+	if !input.CreatedAt.IsZero() {
+		// Stored times are server-local.
+		transaction.CreatedAt = input.CreatedAt.In(time.Local)
+	}
+}
+
+// validationError answers a failed model.ValidateTransaction with a 400 naming the problem.
+func (h *Handler) validationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, model.ErrTransactionTypeInvalid):
+		WriteError(w, http.StatusBadRequest, "type must be 1 (income) or 2 (expense)")
+	case errors.Is(err, model.ErrAmountNotPositive),
+		errors.Is(err, model.ErrUnknownCategory),
+		errors.Is(err, model.ErrCategoryNotAllowed),
+		errors.Is(err, model.ErrUnknownAccount):
+		WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.internalError(w, r, err)
+	}
+}
+
+func (h *Handler) handleListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.storageInstance.GetCategoriesByChatID(UserID(r))
+	if err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+	response := make([]categoryJSON, 0, len(categories))
+	for _, c := range categories {
+		response = append(response, categoryJSON{ID: c.ID, Name: c.Name, Icon: c.Icon, ParentID: c.ParentID, Kind: c.Kind})
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleListAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.storageInstance.GetAccountsByChatID(UserID(r))
+	if err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+	response := make([]accountJSON, 0, len(accounts))
+	for _, a := range accounts {
+		response = append(response, accountJSON{ID: a.ID, Name: a.Name})
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// handleStats returns the category totals and the daily expenses of the period for the charts. The
+// period defaults to the current month; days are cut in the user's time zone.
+func (h *Handler) handleStats(w http.ResponseWriter, r *http.Request) {
+	chatID := UserID(r)
+	from, to, err := parsePeriod(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if from.IsZero() {
+		now := time.Now()
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	if to.IsZero() {
+		to = time.Date(from.Year(), from.Month()+1, 1, 0, 0, 0, 0, time.Local)
+	}
+
+	user, err := h.storageInstance.GetUserByChatID(chatID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		h.internalError(w, r, err)
+		return
+	}
+	rows, err := h.storageInstance.GetTransactionsStatsByCategory(chatID, from, to)
+	if err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+	days, err := h.storageInstance.GetDailyExpenses(chatID, from, to, user.Location().String())
+	if err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+
+	response := statsJSON{
+		Categories: make([]categoryStatJSON, 0, len(rows)),
+		Daily:      make([]dailyAmountJSON, 0, len(days)),
+	}
+	for _, row := range rows {
+		if row.TransactionType == model.TransactionTypeIncome {
+			response.Income += row.Sum
+		} else {
+			response.Expense += row.Sum
+		}
+		response.Categories = append(response.Categories, categoryStatJSON{
+			CategoryID: row.CategoryID,
+			Name:       row.Name,
+			Type:       row.TransactionType,
+			Sum:        row.Sum,
+			Count:      row.Count,
+		})
+	}
+	for _, d := range days {
+		response.Daily = append(response.Daily, dailyAmountJSON{Day: d.Day.Format(dateLayout), Amount: d.Amount})
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// parsePeriod parses inclusive YYYY-MM-DD dates into the bounds of a period in server time, the end being
+// exclusive. Empty dates give zero bounds.
+func parsePeriod(fromText, toText string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if fromText != "" {
+		if from, err = time.ParseInLocation(dateLayout, fromText, time.Local); err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be a YYYY-MM-DD date")
+		}
+	}
+	if toText != "" {
+		if to, err = time.ParseInLocation(dateLayout, toText, time.Local); err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be a YYYY-MM-DD date")
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
+
+func parseOptionalInt(text string) (int64, error) {
+	if text == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(text, 10, 64)
+}
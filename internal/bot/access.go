@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/config"
+	"github.com/cupitman9/budget-bot/internal/metrics"
+)
+
+// accessControl decides who may use the bot: admins always, blocked users never, and everyone else when
+// the allowlist is empty or contains them. Blocked users are kept in memory, storage is the source of truth.
+type accessControl struct {
+	admins  map[int64]struct{}
+	allowed map[int64]struct{}
+
+	mu      sync.RWMutex
+	blocked map[int64]struct{}
+}
+
+// userAccess is the access control of the running bot, shared with the Mini App through Allows.
+var userAccess *accessControl
+
+// Allows reports whether the user may use the bot. Everyone is denied until RegisterHandlers has run.
+func Allows(userID int64) bool {
+	return userAccess != nil && userAccess.Allows(userID)
+}
+
+func newAccessControl(cfg config.AccessConfig, blocked []int64) *accessControl {
+	return &accessControl{
+		admins:  idSet(cfg.AdminIDs),
+		allowed: idSet(cfg.AllowedIDs),
+		blocked: idSet(blocked),
+	}
+}
+
+func idSet(ids []int64) map[int64]struct{} {
+	set := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
+
+func (a *accessControl) IsAdmin(userID int64) bool {
+	_, ok := a.admins[userID]
+	return ok
+}
+
+func (a *accessControl) Allows(userID int64) bool {
+	if a.IsAdmin(userID) {
+		return true
+	}
+	a.mu.RLock()
+	_, blocked := a.blocked[userID]
+	a.mu.RUnlock()
+	if blocked {
+		return false
+	}
+	if len(a.allowed) == 0 {
+		return true
+	}
+	_, ok := a.allowed[userID]
+	return ok
+}
+
+func (a *accessControl) SetBlocked(userID int64, blocked bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if blocked {
+		a.blocked[userID] = struct{}{}
+	} else {
+		delete(a.blocked, userID)
+	}
+}
+
+// restrictAccess drops the updates of users the bot is not open to, telling them their ID so that
+// they can ask an admin for access.
+func restrictAccess(access *accessControl) telebot.MiddlewareFunc {
+	return func(next telebot.HandlerFunc) telebot.HandlerFunc {
+		return func(ctx telebot.Context) error {
+			sender := ctx.Sender()
+			if sender != nil && access.Allows(sender.ID) {
+				return next(ctx)
+			}
+			metrics.AccessDenied.WithLabelValues(updateType(ctx)).Inc()
+			requestLog(ctx).Warn("access denied")
+			if sender == nil || isInline(ctx) {
+				return nil
+			}
+
+			text := fmt.Sprintf("Доступ к боту ограничен. Ваш ID: %d", sender.ID)
+			if ctx.Callback() != nil {
+				return ctx.Respond(&telebot.CallbackResponse{Text: text, ShowAlert: true})
+			}
+			return sendText(ctx.Bot(), ctx.Recipient(), text, nil)
+		}
+	}
+}
+
+// adminOnly guards the admin commands.
+func adminOnly(access *accessControl) telebot.MiddlewareFunc {
+	return func(next telebot.HandlerFunc) telebot.HandlerFunc {
+		return func(ctx telebot.Context) error {
+			if sender := ctx.Sender(); sender != nil && access.IsAdmin(sender.ID) {
+				return next(ctx)
+			}
+			requestLog(ctx).Warn("admin command from non-admin")
+			return sendText(ctx.Bot(), ctx.Recipient(), "Команда доступна только администраторам.", nil)
+		}
+	}
+}
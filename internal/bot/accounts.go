@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
+)
+
+const accountPickerPageSize = 10
+
+// accountPickerPage renders a page of one of the account pickers:
+//   - "accpick:<category id>:<type>:<amount>" chooses the account of an income or expense;
+//   - "trfrom:<amount>" chooses the source account of a transfer;
+//   - "trto:<from account id>:<amount>" chooses its destination.
+func accountPickerPage(accounts []model.Account, route []string, page int, query string) (string, *telebot.ReplyMarkup) {
+	markup := &telebot.ReplyMarkup{}
+
+	var (
+		text     string
+		fromId   string
+		itemData func(id string) string
+	)
+	switch route[0] {
+	case "accpick":
+		text = "Выберите счёт:"
+		itemData = func(id string) string { return "txacc:" + id + ":" + strings.Join(route[1:], ":") }
+	case "trfrom":
+		text = "Перевод " + route[1] + ". Выберите счёт, с которого переводите:"
+		itemData = func(id string) string { return "trto:" + id + ":" + route[1] }
+	case "trto":
+		fromId = route[1]
+		text = "Перевод " + route[2] + ". Выберите счёт, на который переводите:"
+		itemData = func(id string) string { return "transfer:" + fromId + ":" + id + ":" + route[2] }
+	}
+
+	if route[0] != "accpick" && len(accounts) < 2 {
+		markup.Inline()
+		return "Для перевода нужно минимум два счёта. Добавьте счёт командой /add_account.", markup
+	}
+
+	var items []telebot.Btn
+	for _, account := range accounts {
+		id := strconv.FormatInt(account.ID, 10)
+		if id == fromId || !matchesPrefix(account.Name, query) {
+			continue
+		}
+		items = append(items, markup.Data(account.Name, itemData(id)))
+	}
+
+	list := pageList{
+		Items:      items,
+		Columns:    2,
+		PageSize:   accountPickerPageSize,
+		Page:       page,
+		Route:      strings.Join(route, ":"),
+		Query:      query,
+		Searchable: len(accounts) > accountPickerPageSize,
+	}
+	markup.Inline(list.Rows(markup)...)
+
+	if len(items) == 0 {
+		text = "Счета не найдены."
+	}
+	return text, markup
+}
+
+func formatBalances(balances []model.AccountBalance) *render.Message {
+	response := render.New()
+	response.Text("🏦 Баланс по счетам").Line().Line()
+
+	var total float64
+	for _, b := range balances {
+		response.Textf("  - %s: %.2f", b.Account.Name, b.Balance).Line()
+		total += b.Balance
+	}
+
+	response.Line().Textf("Всего: %.2f", total)
+	return response
+}
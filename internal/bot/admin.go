@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/render"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+const adminStatsDays = 7
+
+type adminHandler struct {
+	b               *telebot.Bot
+	storageInstance *storage.Storage
+	access          *accessControl
+	throttle        *throttle
+}
+
+func newAdminHandler(b *telebot.Bot, storageInstance *storage.Storage, access *accessControl) *adminHandler {
+	return &adminHandler{
+		b:               b,
+		storageInstance: storageInstance,
+		access:          access,
+		throttle:        newThrottle(b),
+	}
+}
+
+func (h *adminHandler) handleStats(m *telebot.Message) error {
+	stats, err := h.storageInstance.GetAdminStats(adminStatsDays)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при получении статистики", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	response := render.New()
+	response.Textf("Пользователей: %d", stats.Users).Line()
+	response.Textf("Заблокировано: %d", stats.BlockedUsers).Line().Line()
+	response.Text("Транзакций по дням:").Line()
+	for _, day := range stats.TransactionsPerDay {
+		response.Textf("%s: %d", day.Day.Format("02.01.2006"), day.Count).Line()
+	}
+	return sendRendered(h.b, m.Sender, response, nil)
+}
+
+// handleBroadcast sends the text after the command to every user who is not blocked. Sending is paced
+// by the throttle, so it runs in the background and reports to the admin when done.
+func (h *adminHandler) handleBroadcast(m *telebot.Message, log *logrus.Entry) error {
+	text := strings.TrimSpace(m.Payload)
+	if text == "" {
+		err := sendText(h.b, m.Sender, "Использование: /admin_broadcast <текст сообщения>", nil)
+		return err
+	}
+
+	chatIDs, err := h.storageInstance.GetUserChatIDs()
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при получении списка пользователей", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+	if err := sendText(h.b, m.Sender, fmt.Sprintf("Рассылка начата, получателей: %d", len(chatIDs)), nil); err != nil {
+		return err
+	}
+
+	go func() {
+		delivered := 0
+		for _, chatID := range chatIDs {
+			if err := sendText(h.throttle, telebot.ChatID(chatID), text, nil); err != nil {
+				log.WithField("recipient_id", chatID).WithError(err).Warn("error sending broadcast")
+				continue
+			}
+			delivered++
+		}
+		log.WithField("delivered", delivered).Info("broadcast finished")
+		report := fmt.Sprintf("Рассылка завершена: доставлено %d из %d", delivered, len(chatIDs))
+		if err := sendText(h.b, m.Sender, report, nil); err != nil {
+			log.WithError(err).Error("error sending broadcast report")
+		}
+	}()
+	return nil
+}
+
+// handleBlock blocks or unblocks the user whose ID follows the command.
+func (h *adminHandler) handleBlock(m *telebot.Message, blocked bool) error {
+	command := "/admin_block"
+	if !blocked {
+		command = "/admin_unblock"
+	}
+	userID, err := strconv.ParseInt(strings.TrimSpace(m.Payload), 10, 64)
+	if err != nil {
+		err := sendText(h.b, m.Sender, "Использование: "+command+" <ID пользователя>", nil)
+		return err
+	}
+	if blocked && h.access.IsAdmin(userID) {
+		err := sendText(h.b, m.Sender, "Нельзя заблокировать администратора.", nil)
+		return err
+	}
+
+	var changed bool
+	if blocked {
+		changed, err = h.storageInstance.BlockUser(userID, m.Sender.ID)
+	} else {
+		changed, err = h.storageInstance.UnblockUser(userID)
+	}
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при изменении блокировки", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+	h.access.SetBlocked(userID, blocked)
+
+	var text string
+	switch {
+	case blocked && changed:
+		text = fmt.Sprintf("Пользователь %d заблокирован.", userID)
+	case blocked:
+		text = fmt.Sprintf("Пользователь %d уже заблокирован.", userID)
+	case changed:
+		text = fmt.Sprintf("Пользователь %d разблокирован.", userID)
+	default:
+		text = fmt.Sprintf("Пользователь %d не был заблокирован.", userID)
+	}
+	err = sendText(h.b, m.Sender, text, nil)
+	return err
+}
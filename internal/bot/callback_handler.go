@@ -7,9 +7,10 @@ import (
 	"strings"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus"
 	"gopkg.in/telebot.v3"
 
+	"github.com/cupitman9/budget-bot/internal/logger"
 	"github.com/cupitman9/budget-bot/internal/model"
 	"github.com/cupitman9/budget-bot/internal/storage"
 )
@@ -17,14 +18,16 @@ import (
 type callbackHandler struct {
 	b               *telebot.Bot
 	storageInstance *storage.Storage
-	log             *log.Logger
+	// statsTop is the number of categories listed in stats before "Прочее".
+	statsTop int
 }
 
-func newCallbackHandler(b *telebot.Bot, storageInstance *storage.Storage, log *log.Logger) *callbackHandler {
-	return &callbackHandler{b: b, storageInstance: storageInstance, log: log}
+func newCallbackHandler(b *telebot.Bot, storageInstance *storage.Storage, statsTop int) *callbackHandler {
+	return &callbackHandler{b: b, storageInstance: storageInstance, statsTop: statsTop}
 }
 
-func (h *callbackHandler) handleCallback(c *telebot.Callback) error {
+// handleCallback dispatches a callback on its data prefix. log is the request-scoped entry of the update.
+func (h *callbackHandler) handleCallback(c *telebot.Callback, log *logrus.Entry) error {
 	x := strings.ReplaceAll(c.Data, "\f", "") // telegram or this lib puts \f to data
 	prefixes := strings.Split(x, ":")
 	if len(prefixes) == 0 {
@@ -35,6 +38,9 @@ func (h *callbackHandler) handleCallback(c *telebot.Callback) error {
 
 	switch prefixes[0] {
 	case "rename":
+		if len(prefixes) != 2 {
+			return errUnexpectedData(c)
+		}
 		err := h.handleRenameCallback(c, prefixes[1])
 		if err != nil {
 			return fmt.Errorf("error handling rename callback: %w", err)
@@ -49,24 +55,130 @@ func (h *callbackHandler) handleCallback(c *telebot.Callback) error {
 		if err != nil {
 			return fmt.Errorf("error handling expense callback: %w", err)
 		}
-	case "transaction":
-		err := h.handleTransactionCallback(c)
+	case "subcat", "categories", "accpick", "trfrom", "trto", "goalpick", "debts", "histlog":
+		err := h.handleListingCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling listing callback: %w", err)
+		}
+	case "search":
+		err := h.handleSearchCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling search callback: %w", err)
+		}
+	case "noop": // page indicator, nothing to do
+	case "add_subcategory":
+		err := h.handleAddSubcategoryCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling add subcategory callback: %w", err)
+		}
+	case "category":
+		err := h.handleCategoryMenuCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling category menu callback: %w", err)
+		}
+	case "category_kind":
+		err := h.handleCategoryKindCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling category kind callback: %w", err)
+		}
+	case "category_icon":
+		err := h.handleCategoryIconCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling category icon callback: %w", err)
+		}
+	case "category_move":
+		err := h.handleCategoryMoveCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling category move callback: %w", err)
+		}
+	case "stats_tree":
+		err := h.handleStatsTreeCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling stats tree callback: %w", err)
+		}
+	case "stats_daily":
+		err := h.handleStatsDailyCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling stats daily callback: %w", err)
+		}
+	case "transaction", "txacc":
+		err := h.handleTransactionCallback(c, log)
 		if err != nil {
 			return fmt.Errorf("error handling transaction callback: %w", err)
 		}
+	case "contribute":
+		err := h.handleContributeCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling contribute callback: %w", err)
+		}
+	case "debt_new":
+		err := h.handleNewDebtCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling new debt callback: %w", err)
+		}
+	case "debt":
+		err := h.handleDebtCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling debt callback: %w", err)
+		}
+	case "digest":
+		err := h.handleDigestCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling digest callback: %w", err)
+		}
+	case "quick":
+		err := h.handleQuickCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling quick entry callback: %w", err)
+		}
+	case "remind_snooze":
+		err := h.handleSnoozeCallback(c)
+		if err != nil {
+			return fmt.Errorf("error handling snooze callback: %w", err)
+		}
+	case "transfer":
+		err := h.handleTransferCallback(c, prefixes, log)
+		if err != nil {
+			return fmt.Errorf("error handling transfer callback: %w", err)
+		}
+	case "tpl", "tpl_del", "tpl_new":
+		err := h.handleTemplateCallback(c, prefixes, log)
+		if err != nil {
+			return fmt.Errorf("error handling template callback: %w", err)
+		}
+	case "history":
+		err := h.handleHistoryCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling history callback: %w", err)
+		}
+	case "undo":
+		err := h.handleUndoCallback(c, prefixes)
+		if err != nil {
+			return fmt.Errorf("error handling undo callback: %w", err)
+		}
+	case "delete_me":
+		err := h.handleDeleteMeCallback(c, prefixes, log)
+		if err != nil {
+			return fmt.Errorf("error handling delete me callback: %w", err)
+		}
+	case "reset":
+		err := h.handleResetCallback(c, prefixes, log)
+		if err != nil {
+			return fmt.Errorf("error handling reset callback: %w", err)
+		}
 	case "today":
 		err := h.handleTodayCallback(c)
 		if err != nil {
 			return fmt.Errorf("error handling today callback: %w", err)
 		}
 	case "period":
-		userSessions[c.Sender.ID] = &model.UserSession{State: model.StateAwaitingPeriod}
-		_, err := h.b.Send(c.Sender, "Введите период в формате ДД.ММ.ГГГГ-ДД.ММ.ГГГГ:")
+		userSessions.Set(c.Sender.ID, &model.UserSession{State: model.StateAwaitingPeriod})
+		err := sendText(h.b, c.Sender, "Введите период в формате ДД.ММ.ГГГГ-ДД.ММ.ГГГГ:", nil)
 		if err != nil {
 			return fmt.Errorf("error sending message to choose period: %w", err)
 		}
 	default:
-		_, err := h.b.Send(c.Sender, "Команда не распознана. Пожалуйста, используйте одну из доступных команд.")
+		err := sendText(h.b, c.Sender, "Команда не распознана. Пожалуйста, используйте одну из доступных команд.", nil)
 		if err != nil {
 			return fmt.Errorf("error sending message for undefined callback action: %w", err)
 		}
@@ -74,82 +186,269 @@ func (h *callbackHandler) handleCallback(c *telebot.Callback) error {
 	return nil
 }
 
+// errUnexpectedData reports malformed callback data by its prefix only: the rest may carry amounts, which
+// must not reach the logs.
+func errUnexpectedData(c *telebot.Callback) error {
+	return fmt.Errorf("unexpected %q callback data", callbackAction(c))
+}
+
 func (h *callbackHandler) handleTransactionCategories(c *telebot.Callback) error {
-	categories, err := h.storageInstance.GetCategoriesByChatID(c.Sender.ID)
+	transactionData := strings.ReplaceAll(c.Data, "\f", "")
+	return h.handleListingCallback(c, strings.Split("subcat:0:"+transactionData, ":"))
+}
+
+// handleListingCallback shows the requested page of a paginated listing in place of the current message.
+func (h *callbackHandler) handleListingCallback(c *telebot.Callback, prefixes []string) error {
+	route, page, query, err := splitListingData(prefixes)
+	if err != nil {
+		return err
+	}
+
+	msg, markup, err := renderListing(h.storageInstance, c.Sender.ID, route, page, query)
 	if err != nil {
-		_, sendErr := h.b.Send(c.Sender, "Ошибка при получении категорий.")
+		sendErr := sendText(h.b, c.Sender, "Ошибка при получении списка.", nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
 		return err
 	}
+	return editRendered(h.b, c, msg, markup)
+}
 
-	if len(categories) == 0 {
-		_, err := h.b.Send(c.Sender, "Категории отсутствуют.")
-		if err != nil {
-			return err
+// handleSearchCallback asks for a prefix to filter the listing given by the route after "search:".
+func (h *callbackHandler) handleSearchCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) < 2 {
+		return errUnexpectedData(c)
+	}
+	route, _, _, err := splitListingData(prefixes[1:])
+	if err != nil {
+		return err
+	}
+	userSessions.Set(c.Sender.ID, &model.UserSession{
+		State:       model.StateAwaitingSearchQuery,
+		SearchRoute: route,
+	})
+	err = sendText(h.b, c.Sender, "Введите начало названия:", nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleAddSubcategoryCallback asks for the name of a new category under the chosen one.
+func (h *callbackHandler) handleAddSubcategoryCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	parentID, err := parseCategoryId(prefixes[1])
+	if err != nil {
+		sendErr := sendText(h.b, c.Sender, "Ошибка формата ID категории", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
 		}
-		return nil
+		return err
+	}
+	// The parent is looked up within the sender's categories, so forged data cannot attach to another chat.
+	if _, err := h.storageInstance.GetCategoryByID(c.Sender.ID, parentID); err != nil {
+		sendErr := sendText(h.b, c.Sender, "Категория не найдена.", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
 	}
+	userSessions.Set(c.Sender.ID, &model.UserSession{
+		State:            model.StateAwaitingNewCategoryName,
+		ParentCategoryID: parentID,
+	})
+	err = sendText(h.b, c.Sender, "Введите название новой подкатегории:", nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
 
-	h.log.Infof("found %d categories", len(categories))
+func (h *callbackHandler) handleCategoryMenuCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	categoryId, err := parseCategoryId(prefixes[1])
+	if err != nil {
+		return err
+	}
+	return h.showCategoryMenu(c, categoryId)
+}
 
-	markup := &telebot.ReplyMarkup{}
-	var allRows []telebot.Row
-	var row telebot.Row
+func (h *callbackHandler) showCategoryMenu(c *telebot.Callback, categoryId int64) error {
+	category, err := h.storageInstance.GetCategoryByID(c.Sender.ID, categoryId)
+	if err != nil {
+		sendErr := sendText(h.b, c.Sender, "Категория не найдена.", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
 
-	for i, category := range categories {
-		btn := markup.Data(category.Name, "transaction:"+strconv.Itoa(int(category.ID))+":"+c.Data)
-		row = append(row, btn)
+	text, markup := categoryMenu(category)
+	err = editText(h.b, c, text, markup)
+	if err != nil {
+		return err
+	}
+	return nil
+}
 
-		if (i+1)%3 == 0 || i == len(categories)-1 {
-			allRows = append(allRows, row)
-			row = telebot.Row{}
-		}
+// handleCategoryKindCallback switches the category to the next kind and refreshes its menu.
+func (h *callbackHandler) handleCategoryKindCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	categoryId, err := parseCategoryId(prefixes[1])
+	if err != nil {
+		return err
 	}
-	markup.Inline(allRows...)
-	_, err = h.b.Edit(c.Message, "Выберите категорию:", markup)
+	category, err := h.storageInstance.GetCategoryByID(c.Sender.ID, categoryId)
+	if err != nil {
+		return err
+	}
+	if err := h.storageInstance.SetCategoryKind(c.Sender.ID, categoryId, nextCategoryKind(category.Kind)); err != nil {
+		return err
+	}
+	return h.showCategoryMenu(c, categoryId)
+}
+
+func (h *callbackHandler) handleCategoryIconCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	categoryId, err := parseCategoryId(prefixes[1])
+	if err != nil {
+		return err
+	}
+	userSessions.Set(c.Sender.ID, &model.UserSession{
+		State:      model.StateAwaitingCategoryIcon,
+		CategoryID: int(categoryId),
+	})
+	err = sendText(h.b, c.Sender, "Отправьте эмодзи для категории или '-', чтобы убрать иконку:", nil)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// handleCategoryMoveCallback moves the category among its siblings and shows the updated list.
+// The data is "category_move:<id>:<up|down>".
+func (h *callbackHandler) handleCategoryMoveCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 3 {
+		return errUnexpectedData(c)
+	}
+	categoryId, err := parseCategoryId(prefixes[1])
+	if err != nil {
+		return err
+	}
+	if err := h.storageInstance.MoveCategory(c.Sender.ID, categoryId, prefixes[2] == "up"); err != nil {
+		return err
+	}
+	return h.handleListingCallback(c, []string{"categories"})
+}
+
+// handleStatsTreeCallback re-renders a stats message with subcategories shown or hidden.
+// The data is "stats_tree:<1|0>:<start unix>:<end unix>".
+func (h *callbackHandler) handleStatsTreeCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 4 {
+		return errUnexpectedData(c)
+	}
+	start, errStart := strconv.ParseInt(prefixes[2], 10, 64)
+	end, errEnd := strconv.ParseInt(prefixes[3], 10, 64)
+	if errStart != nil || errEnd != nil {
+		return fmt.Errorf("%v, %v", errStart, errEnd)
+	}
+
+	response, markup, err := buildStats(h.storageInstance, c.Sender.ID,
+		time.Unix(start, 0), time.Unix(end, 0), prefixes[1] == "1", h.statsTop)
+	if err != nil {
+		return err
+	}
+	return editRendered(h.b, c, response, markup)
+}
+
+// handleStatsDailyCallback sends the detailed report for the period of a stats message.
+// The data is "stats_daily:<start unix>:<end unix>".
+func (h *callbackHandler) handleStatsDailyCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 3 {
+		return errUnexpectedData(c)
+	}
+	start, errStart := strconv.ParseInt(prefixes[1], 10, 64)
+	end, errEnd := strconv.ParseInt(prefixes[2], 10, 64)
+	if errStart != nil || errEnd != nil {
+		return fmt.Errorf("%v, %v", errStart, errEnd)
+	}
+
+	response, err := buildDailyStats(h.storageInstance, c.Sender.ID, time.Unix(start, 0), time.Unix(end, 0), time.Now())
+	if err != nil {
+		sendErr := sendText(h.b, c.Sender, "Ошибка при получении статистики: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	return sendRendered(h.b, c.Sender, response, nil)
+}
+
 func (h *callbackHandler) handleRenameCallback(c *telebot.Callback, id string) error {
 	categoryId, err := parseCategoryId(id)
 	if err != nil {
-		_, sendErr := h.b.Send(c.Sender, "Ошибка формата ID категории")
+		sendErr := sendText(h.b, c.Sender, "Ошибка формата ID категории", nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
 		return err
 	}
-	userSessions[c.Sender.ID] = &model.UserSession{
+	userSessions.Set(c.Sender.ID, &model.UserSession{
 		State:      model.StateAwaitingRenameCategory,
 		CategoryID: int(categoryId),
-	}
-	_, err = h.b.Send(c.Sender, "Введите новое название категории:")
+	})
+	err = sendText(h.b, c.Sender, "Введите новое название категории:", nil)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (h *callbackHandler) handleTransactionCallback(c *telebot.Callback) error {
+// handleTransactionCallback books a transaction from "transaction:<category id>:<type>:<amount>" data.
+// When the user has several accounts it first asks for one, and the data comes back as
+// "txacc:<account id>:<category id>:<type>:<amount>".
+func (h *callbackHandler) handleTransactionCallback(c *telebot.Callback, log *logrus.Entry) error {
 	x := strings.ReplaceAll(c.Data, "\f", "")
 	prefixes := strings.Split(strings.TrimSpace(x), ":")
+
+	var accountId int64
+	if prefixes[0] == "txacc" {
+		if len(prefixes) < 2 {
+			return errUnexpectedData(c)
+		}
+		var err error
+		accountId, err = strconv.ParseInt(prefixes[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		prefixes = prefixes[1:]
+	}
+	if len(prefixes) != 4 {
+		return errUnexpectedData(c)
+	}
+
 	categoryId, err := strconv.ParseInt(prefixes[1], 10, 64)
 	if err != nil {
-		_, sendErr := h.b.Send(c.Sender, "Ошибка при обработке категории")
+		sendErr := sendText(h.b, c.Sender, "Ошибка при обработке категории", nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
 		return nil
 	}
 
-	amount, err := strconv.ParseFloat(prefixes[3], 10)
+	amount, createdAt, err := parseTransactionAmount(prefixes[3])
 	if err != nil {
-		_, sendErr := h.b.Send(c.Sender, "Ошибка при обработке суммы")
+		sendErr := sendText(h.b, c.Sender, "Ошибка при обработке суммы", nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
@@ -158,22 +457,378 @@ func (h *callbackHandler) handleTransactionCallback(c *telebot.Callback) error {
 
 	transactionType, err := strconv.ParseUint(prefixes[2], 10, 8)
 	if err != nil {
-		_, sendErr := h.b.Send(c.Sender, "Ошибка при обработке типа транзакции")
+		sendErr := sendText(h.b, c.Sender, "Ошибка при обработке типа транзакции", nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
 		return nil
 	}
 
-	err = h.handleTransaction(c.Sender.ID, categoryId, amount, uint8(transactionType))
+	if accountId == 0 {
+		accounts, err := h.storageInstance.GetAccountsByChatID(c.Sender.ID)
+		if err != nil {
+			return err
+		}
+		if len(accounts) != 1 {
+			return h.handleListingCallback(c, []string{"accpick", prefixes[1], prefixes[2], prefixes[3]})
+		}
+		accountId = accounts[0].ID
+	}
+
+	transactionId, err := handleTransaction(
+		h.storageInstance, c.Sender.ID, accountId, categoryId, amount, uint8(transactionType), createdAt,
+	)
 	if err != nil {
-		_, sendErr := h.b.Send(c.Sender, "Ошибка при создании и сохранении транзакции")
+		sendErr := sendText(h.b, c.Sender, transactionErrorText(err), nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
+		return err
 	}
+	log.WithFields(logrus.Fields{"transaction_id": transactionId, logger.FieldAmount: amount}).
+		Info("transaction added")
 
-	_, err = h.b.Send(c.Sender, fmt.Sprintf("Транзакция на сумму %s добавлена", prefixes[3]))
+	err = sendText(
+		h.b,
+		c.Sender,
+		fmt.Sprintf("Транзакция на сумму %s добавлена", amountText(prefixes[3])),
+		transactionMarkup(h.storageInstance, log, c.Sender.ID, transactionId, true),
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleTransferCallback books a transfer from "transfer:<from account id>:<to account id>:<amount>" data.
+func (h *callbackHandler) handleTransferCallback(c *telebot.Callback, prefixes []string, log *logrus.Entry) error {
+	if len(prefixes) != 4 {
+		return errUnexpectedData(c)
+	}
+	fromId, errFrom := strconv.ParseInt(prefixes[1], 10, 64)
+	toId, errTo := strconv.ParseInt(prefixes[2], 10, 64)
+	if errFrom != nil || errTo != nil {
+		return fmt.Errorf("%v, %v", errFrom, errTo)
+	}
+	amount, err := strconv.ParseFloat(prefixes[3], 64)
+	if err != nil {
+		return err
+	}
+	transfer := model.Transaction{
+		ChatID:          c.Sender.ID,
+		AccountID:       fromId,
+		ToAccountID:     toId,
+		Amount:          amount,
+		TransactionType: model.TransactionTypeTransfer,
+	}
+
+	// Both accounts are checked against the sender's own, so forged data cannot move another chat's balance.
+	accounts, err := h.storageInstance.GetAccountsByChatID(c.Sender.ID)
+	if err != nil {
+		return err
+	}
+	if err := model.ValidateTransfer(transfer, accounts); err != nil {
+		sendErr := sendText(h.b, c.Sender, transactionErrorText(err), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	transactionId, err := h.storageInstance.AddTransaction(transfer)
+	if err != nil {
+		sendErr := sendText(h.b, c.Sender, "Ошибка при сохранении перевода", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+	log.WithFields(logrus.Fields{"transaction_id": transactionId, logger.FieldAmount: amount}).
+		Info("transfer added")
+
+	err = editText(
+		h.b,
+		c,
+		fmt.Sprintf("Перевод на сумму %s добавлен", prefixes[3]),
+		transactionMarkup(h.storageInstance, log, c.Sender.ID, transactionId, false),
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleNewDebtCallback starts recording a debt from "debt_new:<direction>" data.
+func (h *callbackHandler) handleNewDebtCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	direction, err := strconv.ParseUint(prefixes[1], 10, 8)
+	if err != nil || !model.ValidDebtDirection(uint8(direction)) {
+		return errUnexpectedData(c)
+	}
+
+	userSessions.Set(c.Sender.ID, &model.UserSession{
+		State: model.StateAwaitingDebtCounterparty,
+		Debt:  model.Debt{Direction: uint8(direction)},
+	})
+	text := "Кому вы дали в долг?"
+	if uint8(direction) == model.DebtDirectionBorrowed {
+		text = "У кого вы взяли в долг?"
+	}
+	err = editText(h.b, c, text, nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleDebtCallback asks for a repayment of the debt from "debt:<id>" data.
+func (h *callbackHandler) handleDebtCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	debtId, err := strconv.ParseInt(prefixes[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	debt, err := h.storageInstance.GetDebtByID(c.Sender.ID, debtId)
+	if err != nil {
+		sendErr := sendText(h.b, c.Sender, "Долг не найден.", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	userSessions.Set(c.Sender.ID, &model.UserSession{
+		State: model.StateAwaitingDebtRepayment,
+		Debt:  debt,
+	})
+	err = sendText(h.b, c.Sender, debtLabel(debt)+"\nВведите сумму погашения:", nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleDigestCallback turns the digest off or asks for the send time, from "digest:<period>" data.
+func (h *callbackHandler) handleDigestCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	period, err := strconv.ParseUint(prefixes[1], 10, 8)
+	if err != nil {
+		return err
+	}
+
+	if uint8(period) == model.DigestPeriodOff {
+		if err := h.storageInstance.DeleteDigestSettings(c.Sender.ID); err != nil {
+			return err
+		}
+		err = editText(h.b, c, "Сводка выключена.", nil)
+		return err
+	}
+
+	userSessions.Set(c.Sender.ID, &model.UserSession{
+		State:        model.StateAwaitingDigestTime,
+		DigestPeriod: uint8(period),
+	})
+	err = editText(h.b, c, "Сводка "+digestPeriodText(uint8(period))+". Введите время отправки в формате ЧЧ:ММ:", nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleTemplateCallback books a template from "tpl:<id>" data, deletes it from "tpl_del:<id>" data or,
+// from "tpl_new:<transaction id>" data, asks for the name of a template made of the transaction.
+func (h *callbackHandler) handleTemplateCallback(c *telebot.Callback, prefixes []string, log *logrus.Entry) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	id, err := strconv.ParseInt(prefixes[1], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	switch prefixes[0] {
+	case "tpl_new":
+		userSessions.Set(c.Sender.ID, &model.UserSession{
+			State:         model.StateAwaitingTemplateName,
+			TransactionID: id,
+		})
+		err = sendText(h.b, c.Sender, "Введите название шаблона, например Кофе:", nil)
+		return err
+	case "tpl_del":
+		if err := h.storageInstance.DeleteTemplate(c.Sender.ID, id); err != nil {
+			return err
+		}
+		templates, err := h.storageInstance.GetTemplatesByChatID(c.Sender.ID)
+		if err != nil {
+			return err
+		}
+		text, markup := templateList(templates)
+		err = editText(h.b, c, text, markup)
+		return err
+	default:
+		template, err := h.storageInstance.GetTemplateByID(c.Sender.ID, id)
+		if err != nil {
+			return err
+		}
+		transactionId, text, err := bookTemplate(h.storageInstance, template)
+		if err != nil {
+			sendErr := sendText(h.b, c.Sender, transactionErrorText(err), nil)
+			if sendErr != nil {
+				return fmt.Errorf("%v: %w", err, sendErr)
+			}
+			return err
+		}
+		markup := transactionMarkup(h.storageInstance, log, c.Sender.ID, transactionId, false)
+		err = sendText(h.b, c.Sender, text, markup)
+		return err
+	}
+}
+
+// handleHistoryCallback shows the changes of a category from "history:<category id>" data.
+func (h *callbackHandler) handleHistoryCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	categoryId, err := parseCategoryId(prefixes[1])
+	if err != nil {
+		return err
+	}
+	history, markup, err := buildHistory(h.storageInstance, c.Sender.ID, model.AuditEntityCategory, categoryId, 0)
+	if err != nil {
+		return err
+	}
+	return sendRendered(h.b, c.Sender, history, markup)
+}
+
+// handleUndoCallback reverts the action of the confirmation message from "undo:<kind>:<target id>" data,
+// provided it is still the last action of the user.
+func (h *callbackHandler) handleUndoCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 3 {
+		return errUnexpectedData(c)
+	}
+	kind, errKind := strconv.ParseUint(prefixes[1], 10, 8)
+	targetID, errTarget := strconv.ParseInt(prefixes[2], 10, 64)
+	if errKind != nil || errTarget != nil {
+		return fmt.Errorf("%v, %v", errKind, errTarget)
+	}
+
+	action, err := h.storageInstance.UndoLastAction(c.Sender.ID, undoWindow, uint8(kind), targetID)
+	if err != nil {
+		sendErr := sendText(h.b, c.Sender, undoText(action, err), nil)
+		if isUndoFailure(err) {
+			return sendErr
+		}
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+	err = editText(h.b, c, undoText(action, nil), nil)
+	return err
+}
+
+// handleDeleteMeCallback walks through /delete_me from "delete_me:<export|confirm|final|cancel>" data.
+func (h *callbackHandler) handleDeleteMeCallback(c *telebot.Callback, prefixes []string, log *logrus.Entry) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+
+	switch prefixes[1] {
+	case "export":
+		document, err := buildExport(h.storageInstance, c.Sender.ID, time.Now())
+		if err != nil {
+			sendErr := sendText(h.b, c.Sender, "Ошибка при выгрузке данных", nil)
+			if sendErr != nil {
+				return fmt.Errorf("%v: %w", err, sendErr)
+			}
+			return err
+		}
+		_, err = h.b.Send(c.Sender, document)
+		return err
+	case "confirm":
+		err := editText(h.b, c, deleteMeConfirmText, deleteMeConfirmMarkup())
+		return err
+	case "final":
+		if err := h.storageInstance.DeleteUserData(c.Sender.ID); err != nil {
+			sendErr := sendText(h.b, c.Sender, "Ошибка при удалении данных", nil)
+			if sendErr != nil {
+				return fmt.Errorf("%v: %w", err, sendErr)
+			}
+			return err
+		}
+		userSessions.Delete(c.Sender.ID)
+		log.Info("user data deleted")
+		err := editText(h.b, c, "Ваши данные удалены. Чтобы начать заново, отправьте /start.", nil)
+		return err
+	case "cancel":
+		err := editText(h.b, c, "Удаление отменено.", nil)
+		return err
+	default:
+		return errUnexpectedData(c)
+	}
+}
+
+// handleResetCallback deletes the transactions of the user from "reset:<confirm|cancel>" data.
+func (h *callbackHandler) handleResetCallback(c *telebot.Callback, prefixes []string, log *logrus.Entry) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	if prefixes[1] != "confirm" {
+		err := editText(h.b, c, "Сброс отменён.", nil)
+		return err
+	}
+
+	deleted, err := h.storageInstance.DeleteTransactions(c.Sender.ID)
+	if err != nil {
+		sendErr := sendText(h.b, c.Sender, "Ошибка при удалении транзакций", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+	userSessions.Delete(c.Sender.ID)
+	log.WithField("deleted", deleted).Info("transactions reset")
+	err = editText(h.b, c, fmt.Sprintf("Удалено транзакций: %d", deleted), nil)
+	return err
+}
+
+// handleQuickCallback asks for the amount to book to the category chosen on the reminder keyboard,
+// from "quick:<category id>" data.
+func (h *callbackHandler) handleQuickCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 2 {
+		return errUnexpectedData(c)
+	}
+	categoryId, err := parseCategoryId(prefixes[1])
+	if err != nil {
+		return err
+	}
+	category, err := h.storageInstance.GetCategoryByID(c.Sender.ID, categoryId)
+	if err != nil {
+		return err
+	}
+
+	userSessions.Set(c.Sender.ID, &model.UserSession{
+		State:      model.StateAwaitingQuickAmount,
+		CategoryID: int(categoryId),
+	})
+	err = sendText(h.b, c.Sender, "Введите сумму для категории "+category.Label()+":", nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h *callbackHandler) handleSnoozeCallback(c *telebot.Callback) error {
+	if err := h.storageInstance.SnoozeReminder(c.Sender.ID, time.Now().Add(reminderSnooze)); err != nil {
+		return err
+	}
+	err := editText(h.b, c, "Хорошо, напомню через час.", nil)
 	if err != nil {
 		return err
 	}
@@ -192,37 +847,111 @@ func (h *callbackHandler) handleTodayCallback(c *telebot.Callback) error {
 	return nil
 }
 
-func (h *callbackHandler) handleTransaction(senderId, categoryId int64, amount float64, transactionType uint8) error {
+// transactionBooker is the part of the storage that books transactions.
+type transactionBooker interface {
+	ValidateTransaction(transaction model.Transaction) error
+	AddTransaction(transaction model.Transaction) (int64, error)
+}
+
+// handleTransaction validates and stores an income or an expense, at createdAt or now when it is zero. Every
+// booking path of the bot goes through it, and the API runs the same storage.ValidateTransaction check.
+func handleTransaction(
+	storageInstance transactionBooker,
+	senderId, accountId, categoryId int64,
+	amount float64,
+	transactionType uint8,
+	createdAt time.Time,
+) (int64, error) {
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
 	transaction := model.Transaction{
 		ChatID:          senderId,
 		CategoryID:      categoryId,
+		AccountID:       accountId,
 		Amount:          amount,
 		TransactionType: transactionType,
-		CreatedAt:       time.Now(),
+		CreatedAt:       createdAt,
 	}
-
-	if err := h.storageInstance.AddTransaction(transaction); err != nil {
-		return err
+	if err := storageInstance.ValidateTransaction(transaction); err != nil {
+		return 0, err
 	}
 
-	return nil
+	return storageInstance.AddTransaction(transaction)
 }
 
-func (h *callbackHandler) handleStats(sender *telebot.User, startDate, endDate time.Time) error {
-	incomeCategories, expenseCategories, err := h.storageInstance.GetTransactionsStatsByCategory(sender.ID, startDate, endDate)
+// transactionMarkup offers to undo a freshly booked transaction, to attribute it to a savings goal when
+// the user has goals and, unless it is a transfer, to save it as a template.
+func transactionMarkup(
+	storageInstance *storage.Storage,
+	log *logrus.Entry,
+	chatID, transactionId int64,
+	template bool,
+) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	id := strconv.FormatInt(transactionId, 10)
+
+	var row telebot.Row
+	goals, err := storageInstance.GetGoalsByChatID(chatID)
 	if err != nil {
-		_, sendErr := h.b.Send(sender, "Ошибка при получении статистики: "+err.Error())
+		log.WithError(err).Error("error getting goals")
+	}
+	if len(goals) > 0 {
+		row = append(row, markup.Data("🎯 В цель", "goalpick:"+id))
+	}
+	if template {
+		row = append(row, markup.Data("⭐ В шаблоны", "tpl_new:"+id))
+	}
+	markup.Inline(row, markup.Row(undoButton(markup, model.ActionAddTransaction, transactionId)))
+	return markup
+}
+
+// handleContributeCallback attributes a transaction to a goal from "contribute:<goal id>:<transaction id>" data.
+func (h *callbackHandler) handleContributeCallback(c *telebot.Callback, prefixes []string) error {
+	if len(prefixes) != 3 {
+		return errUnexpectedData(c)
+	}
+	goalId, errGoal := strconv.ParseInt(prefixes[1], 10, 64)
+	transactionId, errTransaction := strconv.ParseInt(prefixes[2], 10, 64)
+	if errGoal != nil || errTransaction != nil {
+		return fmt.Errorf("%v, %v", errGoal, errTransaction)
+	}
+
+	err := h.storageInstance.AddGoalContribution(c.Sender.ID, goalId, transactionId)
+	if errors.Is(err, storage.ErrAlreadyContributed) {
+		err := editText(h.b, c, "Эта транзакция уже учтена в цели.", nil)
+		return err
+	}
+	if err != nil {
+		sendErr := sendText(h.b, c.Sender, "Ошибка при добавлении взноса в цель", nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
 		return err
 	}
 
-	response := getStats(incomeCategories, expenseCategories)
-
-	_, err = h.b.Send(sender, response, &telebot.SendOptions{ParseMode: telebot.ModeMarkdown})
+	goals, err := h.storageInstance.GetGoalsByChatID(c.Sender.ID)
 	if err != nil {
 		return err
 	}
+	for _, goal := range goals {
+		if goal.ID == goalId {
+			err = editText(h.b, c, "Взнос учтён.\n\n"+formatGoal(goal, time.Now()), nil)
+			return err
+		}
+	}
 	return nil
 }
+
+func (h *callbackHandler) handleStats(sender *telebot.User, startDate, endDate time.Time) error {
+	response, markup, err := buildStats(h.storageInstance, sender.ID, startDate, endDate, false, h.statsTop)
+	if err != nil {
+		sendErr := sendText(h.b, sender, "Ошибка при получении статистики: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	return sendRendered(h.b, sender, response, markup)
+}
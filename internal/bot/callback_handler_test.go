@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+// fakeBooker validates against a fixed ledger and records the booked transactions.
+type fakeBooker struct {
+	categories map[int64]model.Category
+	accounts   []model.Account
+	booked     []model.Transaction
+}
+
+func (f *fakeBooker) ValidateTransaction(transaction model.Transaction) error {
+	var category *model.Category
+	if c, ok := f.categories[transaction.CategoryID]; ok && c.ChatID == transaction.ChatID {
+		category = &c
+	}
+	return model.ValidateTransaction(transaction, category, f.accounts)
+}
+
+func (f *fakeBooker) AddTransaction(transaction model.Transaction) (int64, error) {
+	f.booked = append(f.booked, transaction)
+	return int64(len(f.booked)), nil
+}
+
+func TestHandleTransactionCategoryKind(t *testing.T) {
+	const chatID = 1
+	newBooker := func() *fakeBooker {
+		return &fakeBooker{
+			categories: map[int64]model.Category{
+				10: {ID: 10, ChatID: chatID, Name: "Продукты", Kind: model.CategoryKindExpense},
+				11: {ID: 11, ChatID: chatID, Name: "Зарплата", Kind: model.CategoryKindIncome},
+				12: {ID: 12, ChatID: chatID, Name: "Разное", Kind: model.CategoryKindBoth},
+				20: {ID: 20, ChatID: 2, Name: "Чужая", Kind: model.CategoryKindBoth},
+			},
+			accounts: []model.Account{{ID: 100, ChatID: chatID}},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		categoryID      int64
+		accountID       int64
+		transactionType uint8
+		want            error
+	}{
+		{"expense in expense category", 10, 100, model.TransactionTypeExpense, nil},
+		{"income in income category", 11, 100, model.TransactionTypeIncome, nil},
+		{"income in category of both kinds", 12, 100, model.TransactionTypeIncome, nil},
+		{"income in expense category", 10, 100, model.TransactionTypeIncome, model.ErrCategoryNotAllowed},
+		{"expense in income category", 11, 100, model.TransactionTypeExpense, model.ErrCategoryNotAllowed},
+		{"category of another chat", 20, 100, model.TransactionTypeExpense, model.ErrUnknownCategory},
+		{"account of another chat", 10, 200, model.TransactionTypeExpense, model.ErrUnknownAccount},
+		{"transfer", 10, 100, model.TransactionTypeTransfer, model.ErrTransactionTypeInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			booker := newBooker()
+			id, err := handleTransaction(booker, chatID, tt.accountID, tt.categoryID, 350, tt.transactionType, time.Time{})
+			if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Fatalf("handleTransaction() error = %v, want %v", err, tt.want)
+			}
+			if tt.want != nil {
+				if len(booker.booked) != 0 || id != 0 {
+					t.Errorf("rejected transaction was booked: %+v", booker.booked)
+				}
+				return
+			}
+			if len(booker.booked) != 1 || booker.booked[0].CreatedAt.IsZero() {
+				t.Errorf("booked = %+v, want one transaction with a time", booker.booked)
+			}
+		})
+	}
+}
+
+func TestTransactionErrorText(t *testing.T) {
+	if got := transactionErrorText(model.ErrCategoryNotAllowed); got != "Эта категория не подходит для такого типа транзакции." {
+		t.Errorf("transactionErrorText(ErrCategoryNotAllowed) = %q", got)
+	}
+	if got := transactionErrorText(errors.New("connection refused")); got != "Ошибка при создании и сохранении транзакции" {
+		t.Errorf("transactionErrorText(other) = %q", got)
+	}
+}
@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+func categoryKindText(kind uint8) string {
+	switch kind {
+	case model.CategoryKindIncome:
+		return "Доход"
+	case model.CategoryKindExpense:
+		return "Расход"
+	default:
+		return "Доход и расход"
+	}
+}
+
+// nextCategoryKind cycles both -> expense -> income -> both.
+func nextCategoryKind(kind uint8) uint8 {
+	switch kind {
+	case model.CategoryKindBoth:
+		return model.CategoryKindExpense
+	case model.CategoryKindExpense:
+		return model.CategoryKindIncome
+	default:
+		return model.CategoryKindBoth
+	}
+}
+
+// categoryListPage renders a page of /show_categories: the category tree, one button per category
+// opening its menu. While searching, matching categories are shown flat with their full path.
+func categoryListPage(categories []model.Category, page int, query string) (string, *telebot.ReplyMarkup) {
+	markup := &telebot.ReplyMarkup{}
+	tree := newCategoryTree(categories)
+
+	var items []telebot.Btn
+	tree.walk(func(category model.Category, depth int) {
+		if !matchesPrefix(category.Name, query) {
+			return
+		}
+		text := category.Label()
+		switch {
+		case query != "":
+			text = categoryPathLabel(tree, category)
+		case depth > 0:
+			text = strings.Repeat("  ", depth) + "↳ " + text
+		}
+		items = append(items, markup.Data(text, "category:"+strconv.FormatInt(category.ID, 10)))
+	})
+
+	list := pageList{
+		Items:      items,
+		Columns:    1,
+		PageSize:   categoryListPageSize,
+		Page:       page,
+		Route:      "categories",
+		Query:      query,
+		Searchable: true,
+	}
+	markup.Inline(list.Rows(markup)...)
+
+	text := "Категории:"
+	if len(items) == 0 {
+		text = "Категории не найдены."
+	}
+	return text, markup
+}
+
+// categoryPickerPage renders a page of the transaction category picker at parentID, 0 being the top level.
+// transactionData is "<transaction type>:<amount>" carried through to the transaction callback.
+// While searching, matching categories of every level are offered directly.
+func categoryPickerPage(
+	categories []model.Category,
+	parentID int64,
+	transactionData string,
+	page int,
+	query string,
+) (string, *telebot.ReplyMarkup) {
+	markup := &telebot.ReplyMarkup{}
+	tree := newCategoryTree(categories)
+
+	var items []telebot.Btn
+	if query != "" {
+		tree.walk(func(category model.Category, _ int) {
+			if matchesPrefix(category.Name, query) {
+				id := strconv.FormatInt(category.ID, 10)
+				items = append(items, markup.Data(categoryPathLabel(tree, category), "transaction:"+id+":"+transactionData))
+			}
+		})
+	} else {
+		for _, category := range tree.children[parentID] {
+			id := strconv.FormatInt(category.ID, 10)
+			btn := markup.Data(category.Label(), "transaction:"+id+":"+transactionData)
+			if tree.hasChildren(category.ID) {
+				btn = markup.Data(category.Label()+" ›", "subcat:"+id+":"+transactionData)
+			}
+			items = append(items, btn)
+		}
+	}
+
+	columns := 3
+	if query != "" {
+		columns = 1
+	}
+	list := pageList{
+		Items:      items,
+		Columns:    columns,
+		PageSize:   categoryPickerPageSize,
+		Page:       page,
+		Route:      "subcat:" + strconv.FormatInt(parentID, 10) + ":" + transactionData,
+		Query:      query,
+		Searchable: true,
+	}
+	rows := list.Rows(markup)
+
+	text := "Выберите категорию:"
+	if parent, ok := tree.byID[parentID]; ok && query == "" {
+		text = "Выберите подкатегорию: " + tree.path(parentID)
+		btnParent := markup.Data("✓ "+parent.Label(), "transaction:"+strconv.FormatInt(parentID, 10)+":"+transactionData)
+		backID := parent.ParentID
+		if _, ok := tree.byID[backID]; !ok {
+			backID = 0
+		}
+		btnBack := markup.Data("‹ Назад", "subcat:"+strconv.FormatInt(backID, 10)+":"+transactionData)
+		rows = append(rows, markup.Row(btnBack, btnParent))
+	}
+	if len(items) == 0 {
+		text = "Категории не найдены."
+	}
+
+	markup.Inline(rows...)
+	return text, markup
+}
+
+func categoryPathLabel(tree *categoryTree, category model.Category) string {
+	if category.Icon == "" {
+		return tree.path(category.ID)
+	}
+	return category.Icon + " " + tree.path(category.ID)
+}
+
+// categoryMenu describes a category and offers the actions available for it.
+func categoryMenu(category model.Category) (string, *telebot.ReplyMarkup) {
+	id := strconv.FormatInt(category.ID, 10)
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(
+		markup.Row(
+			markup.Data("Переименовать", "rename:"+id),
+			markup.Data("Подкатегория", "add_subcategory:"+id),
+		),
+		markup.Row(
+			markup.Data("Тип: "+categoryKindText(category.Kind), "category_kind:"+id),
+			markup.Data("Иконка", "category_icon:"+id),
+		),
+		markup.Row(
+			markup.Data("▲ Выше", "category_move:"+id+":up"),
+			markup.Data("▼ Ниже", "category_move:"+id+":down"),
+		),
+		markup.Row(
+			markup.Data("🕘 История", "history:"+id),
+			markup.Data("‹ К списку", "categories"),
+		),
+	)
+	text := "Категория: " + category.Label() + "\nТип: " + categoryKindText(category.Kind)
+	return text, markup
+}
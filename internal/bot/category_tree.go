@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+const categoryPathSeparator = " → "
+
+// categoryTree indexes a flat list of categories by ID and by parent. Children keep the order of the input list.
+type categoryTree struct {
+	byID     map[int64]model.Category
+	children map[int64][]model.Category
+}
+
+func newCategoryTree(categories []model.Category) *categoryTree {
+	t := &categoryTree{
+		byID:     make(map[int64]model.Category, len(categories)),
+		children: make(map[int64][]model.Category),
+	}
+	for _, c := range categories {
+		t.byID[c.ID] = c
+	}
+	for _, c := range categories {
+		parentID := c.ParentID
+		if _, ok := t.byID[parentID]; !ok {
+			parentID = 0
+		}
+		t.children[parentID] = append(t.children[parentID], c)
+	}
+	return t
+}
+
+func (t *categoryTree) hasChildren(id int64) bool {
+	return len(t.children[id]) > 0
+}
+
+// path returns the names from the top-level category down to id, e.g. "Транспорт → Такси".
+func (t *categoryTree) path(id int64) string {
+	var names []string
+	for c, ok := t.byID[id]; ok; c, ok = t.byID[c.ParentID] {
+		names = append([]string{c.Name}, names...)
+		if len(names) > len(t.byID) {
+			break
+		}
+	}
+	return strings.Join(names, categoryPathSeparator)
+}
+
+// walk visits categories depth-first, parents before their children.
+func (t *categoryTree) walk(fn func(c model.Category, depth int)) {
+	var visit func(parentID int64, depth int)
+	visit = func(parentID int64, depth int) {
+		for _, c := range t.children[parentID] {
+			fn(c, depth)
+			visit(c.ID, depth+1)
+		}
+	}
+	visit(0, 0)
+}
+
+// rollUp returns per-category totals that include the sums of all descendants.
+func (t *categoryTree) rollUp(sums map[int64]float64) map[int64]float64 {
+	totals := make(map[int64]float64, len(sums))
+	for id, amount := range sums {
+		seen := 0
+		for c, ok := t.byID[id]; ok && seen <= len(t.byID); c, ok = t.byID[c.ParentID] {
+			totals[c.ID] += amount
+			seen++
+		}
+	}
+	return totals
+}
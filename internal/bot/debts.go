@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+const debtListPageSize = 10
+
+func debtDirectionText(direction uint8) string {
+	if direction == model.DebtDirectionBorrowed {
+		return "я должен"
+	}
+	return "мне должны"
+}
+
+func debtLabel(debt model.Debt) string {
+	label := fmt.Sprintf("%s: %.2f (%s)", debt.Counterparty, debt.Remaining(), debtDirectionText(debt.Direction))
+	if !debt.DueDate.IsZero() {
+		label += " до " + debt.DueDate.Format("02.01.2006")
+	}
+	return label
+}
+
+// formatDebtBalances sums open debts per counterparty, ignoring case of the name. A positive balance
+// means the counterparty owes the user.
+func formatDebtBalances(debts []model.Debt) string {
+	balances := make(map[string]float64)
+	names := make(map[string]string)
+	for _, debt := range debts {
+		key := strings.ToLower(debt.Counterparty)
+		names[key] = debt.Counterparty
+		if debt.Direction == model.DebtDirectionBorrowed {
+			balances[key] -= debt.Remaining()
+		} else {
+			balances[key] += debt.Remaining()
+		}
+	}
+
+	keys := make([]string, 0, len(balances))
+	for key := range balances {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var response strings.Builder
+	response.WriteString("🤝 Долги\n\n")
+	for _, key := range keys {
+		balance := balances[key]
+		switch {
+		case balance > 0:
+			response.WriteString(fmt.Sprintf("  - %s должен вам %.2f\n", names[key], balance))
+		case balance < 0:
+			response.WriteString(fmt.Sprintf("  - вы должны %s %.2f\n", names[key], -balance))
+		default:
+			response.WriteString(fmt.Sprintf("  - %s: в расчёте\n", names[key]))
+		}
+	}
+	response.WriteString("\nЧтобы записать погашение, выберите долг:")
+	return response.String()
+}
+
+// debtListPage renders a page of open debts, each opening the repayment prompt.
+func debtListPage(debts []model.Debt, page int, query string) (string, *telebot.ReplyMarkup) {
+	markup := &telebot.ReplyMarkup{}
+
+	var items []telebot.Btn
+	for _, debt := range debts {
+		if !matchesPrefix(debt.Counterparty, query) {
+			continue
+		}
+		items = append(items, markup.Data(debtLabel(debt), "debt:"+strconv.FormatInt(debt.ID, 10)))
+	}
+
+	list := pageList{
+		Items:      items,
+		Columns:    1,
+		PageSize:   debtListPageSize,
+		Page:       page,
+		Route:      "debts",
+		Query:      query,
+		Searchable: true,
+	}
+	markup.Inline(list.Rows(markup)...)
+
+	if len(debts) == 0 {
+		return "Открытых долгов нет. Добавьте долг командой /debt.", markup
+	}
+	return formatDebtBalances(debts), markup
+}
+
+func debtReminderText(debt model.Debt) string {
+	if debt.Direction == model.DebtDirectionBorrowed {
+		return fmt.Sprintf("⏰ Сегодня срок возврата долга: вы должны %s %.2f.", debt.Counterparty, debt.Remaining())
+	}
+	return fmt.Sprintf("⏰ Сегодня срок возврата долга: %s должен вам %.2f.", debt.Counterparty, debt.Remaining())
+}
@@ -0,0 +1,205 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+const (
+	digestTopCategories = 3
+	// digestMaxCatchUp limits how many missed digests are sent after a long downtime.
+	digestMaxCatchUp = 7
+)
+
+var utcOffsetPattern = regexp.MustCompile(`^(?i)(?:utc|gmt)?\s*([+-])\s*(\d{1,2})$`)
+
+func digestPeriodText(period uint8) string {
+	switch period {
+	case model.DigestPeriodDaily:
+		return "ежедневно"
+	case model.DigestPeriodWeekly:
+		return "еженедельно по понедельникам"
+	case model.DigestPeriodMonthly:
+		return "ежемесячно 1-го числа"
+	default:
+		return "выключена"
+	}
+}
+
+func digestTitle(period uint8, start, end time.Time) string {
+	switch period {
+	case model.DigestPeriodWeekly:
+		return fmt.Sprintf("🗓 Сводка за неделю %s–%s", start.Format("02.01"), end.AddDate(0, 0, -1).Format("02.01.2006"))
+	case model.DigestPeriodMonthly:
+		return "🗓 Сводка за " + start.Format("01.2006")
+	default:
+		return "🗓 Сводка за " + start.Format("02.01.2006")
+	}
+}
+
+// parseSendTime reads a local time of day in the "ЧЧ:ММ" format as minutes after midnight.
+func parseSendTime(text string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(text))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseTimezone accepts an IANA name such as "Europe/Moscow" or a whole-hour UTC offset such as "+3" or "UTC-5".
+func parseTimezone(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if match := utcOffsetPattern.FindStringSubmatch(text); match != nil {
+		hours, err := strconv.Atoi(match[2])
+		if err != nil {
+			return "", err
+		}
+		if hours == 0 {
+			return "UTC", nil
+		}
+		// Etc/GMT zones have the sign inverted: Etc/GMT-3 is UTC+3.
+		sign := "-"
+		if match[1] == "-" {
+			sign = "+"
+		}
+		text = "Etc/GMT" + sign + strconv.Itoa(hours)
+	}
+	if _, err := time.LoadLocation(text); err != nil || text == "" || strings.EqualFold(text, "local") {
+		return "", fmt.Errorf("unknown time zone %q", text)
+	}
+	return text, nil
+}
+
+func digestMarkup() *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(
+		markup.Row(
+			markup.Data("Ежедневно", "digest:"+strconv.Itoa(int(model.DigestPeriodDaily))),
+			markup.Data("По понедельникам", "digest:"+strconv.Itoa(int(model.DigestPeriodWeekly))),
+		),
+		markup.Row(
+			markup.Data("1-го числа", "digest:"+strconv.Itoa(int(model.DigestPeriodMonthly))),
+			markup.Data("Выключить", "digest:"+strconv.Itoa(int(model.DigestPeriodOff))),
+		),
+	)
+	return markup
+}
+
+// buildDigest renders the stats of the period with its top expense categories and the account balances.
+func buildDigest(
+	storageInstance *storage.Storage,
+	chatID int64,
+	period uint8,
+	startDate, endDate time.Time,
+	statsTop int,
+) (*render.Message, *telebot.ReplyMarkup, error) {
+	stats, markup, err := buildStats(storageInstance, chatID, startDate, endDate, false, statsTop)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	from, to := storedRange(startDate, endDate)
+	rows, err := storageInstance.GetTransactionsStatsByCategory(chatID, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	categories, err := storageInstance.GetCategoriesByChatID(chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	balances, err := storageInstance.GetAccountBalances(chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response := render.New()
+	response.Bold(digestTitle(period, startDate, endDate)).Line().Line()
+	response.Append(stats)
+
+	expenses := categorySums(rows, model.TransactionTypeExpense)
+	if top := topCategories(newCategoryTree(categories), expenses, digestTopCategories); len(top) > 0 {
+		response.Line().Line().Text("🔝 ").Bold("Больше всего потрачено").Line()
+		for i, line := range top {
+			response.Textf("  %d. %s", i+1, line).Line()
+		}
+	}
+
+	if len(balances) > 0 {
+		response.Line().Append(formatBalances(balances))
+	}
+
+	return response, markup, nil
+}
+
+// topCategories returns the top-level categories with the largest rolled-up sums as "name: sum" lines.
+func topCategories(tree *categoryTree, sums map[int64]float64, limit int) []string {
+	totals := tree.rollUp(sums)
+	top := sortedByTotal(tree.children[0], totals)
+	lines := make([]string, 0, limit)
+	for _, c := range top[:min(limit, len(top))] {
+		lines = append(lines, fmt.Sprintf("%s: %.1f", c.Label(), totals[c.ID]))
+	}
+	return lines
+}
+
+// sendDigests sends every digest that became due since its last-sent marker. The marker is moved before
+// sending, so a digest is never sent twice; it is moved back when sending fails, so that the next run retries.
+func sendDigests(t *throttle, storageInstance *storage.Storage, statsTop int, log *logrus.Logger, now time.Time) {
+	settings, err := storageInstance.GetDigestSettings()
+	if err != nil {
+		log.WithError(err).Error("error getting digest settings")
+		return
+	}
+
+	for _, s := range settings {
+		prev := s.LastSentAt
+		for i := 0; i < digestMaxCatchUp; i++ {
+			due := s.NextDue(prev)
+			if due.After(now) {
+				break
+			}
+
+			claimed, err := storageInstance.ClaimDigest(s.ChatID, prev, due)
+			if err != nil || !claimed {
+				if err != nil {
+					log.WithField("chat_id", s.ChatID).WithError(err).Error("error claiming digest")
+				}
+				break
+			}
+
+			if err := sendDigest(t, storageInstance, statsTop, s, due); err != nil {
+				log.WithField("chat_id", s.ChatID).WithError(err).Error("error sending digest")
+				if _, err := storageInstance.ClaimDigest(s.ChatID, due, prev); err != nil {
+					log.WithField("chat_id", s.ChatID).WithError(err).Error("error restoring digest marker")
+				}
+				break
+			}
+			prev = due
+		}
+	}
+}
+
+func sendDigest(
+	t *throttle,
+	storageInstance *storage.Storage,
+	statsTop int,
+	settings model.DigestSettings,
+	due time.Time,
+) error {
+	startDate, endDate := settings.Range(due)
+	msg, markup, err := buildDigest(storageInstance, settings.ChatID, settings.Period, startDate, endDate, statsTop)
+	if err != nil {
+		return err
+	}
+	return sendRendered(t, telebot.ChatID(settings.ChatID), msg, markup)
+}
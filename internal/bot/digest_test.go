@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+// useLocal runs the test with time.Local set to loc, the zone of the server.
+func useLocal(t *testing.T, loc *time.Location) {
+	t.Helper()
+	local := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = local })
+}
+
+func TestDigestStoredRange(t *testing.T) {
+	useLocal(t, time.UTC)
+	now := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC) // Monday
+
+	tests := []struct {
+		period    uint8
+		wantFrom  string
+		wantTo    string
+		wantTitle string
+	}{
+		// midnights of Moscow are 21:00 of the previous day on the UTC server
+		{model.DigestPeriodDaily, "2024-06-01 21:00", "2024-06-02 21:00", "🗓 Сводка за 02.06.2024"},
+		{model.DigestPeriodWeekly, "2024-05-26 21:00", "2024-06-02 21:00", "🗓 Сводка за неделю 27.05–02.06.2024"},
+		{model.DigestPeriodMonthly, "2024-04-30 21:00", "2024-05-31 21:00", "🗓 Сводка за 05.2024"},
+	}
+	for _, tt := range tests {
+		settings := model.DigestSettings{Period: tt.period, SendMinute: 9 * 60, Timezone: "Europe/Moscow"}
+		start, end := settings.Range(settings.LastDue(now))
+		from, to := storedRange(start, end)
+
+		const layout = "2006-01-02 15:04"
+		if from.Location() != time.Local || from.Format(layout) != tt.wantFrom || to.Format(layout) != tt.wantTo {
+			t.Errorf("period %d: stored range = %v – %v, want %s – %s in time.Local",
+				tt.period, from, to, tt.wantFrom, tt.wantTo)
+		}
+		if title := digestTitle(tt.period, start, end); title != tt.wantTitle {
+			t.Errorf("period %d: digestTitle() = %q, want %q", tt.period, title, tt.wantTitle)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+// userExport is the JSON document a user gets before deleting their data.
+type userExport struct {
+	ExportedAt   time.Time           `json:"exported_at"`
+	ChatID       int64               `json:"chat_id"`
+	Username     string              `json:"username"`
+	Timezone     string              `json:"timezone"`
+	CreatedAt    time.Time           `json:"created_at"`
+	Categories   []exportCategory    `json:"categories"`
+	Accounts     []exportAccount     `json:"accounts"`
+	Transactions []exportTransaction `json:"transactions"`
+	Goals        []exportGoal        `json:"goals"`
+	Debts        []exportDebt        `json:"debts"`
+	Templates    []exportTemplate    `json:"templates"`
+	Digest       *exportDigest       `json:"digest,omitempty"`
+	Reminder     *exportReminder     `json:"reminder,omitempty"`
+	APIToken     *exportAPIToken     `json:"api_token,omitempty"`
+}
+
+type exportCategory struct {
+	ID       int64  `json:"id"`
+	ParentID int64  `json:"parent_id,omitempty"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Icon     string `json:"icon,omitempty"`
+}
+
+type exportAccount struct {
+	ID             int64   `json:"id"`
+	Name           string  `json:"name"`
+	OpeningBalance float64 `json:"opening_balance"`
+}
+
+type exportTransaction struct {
+	ID          int64     `json:"id"`
+	Type        string    `json:"type"`
+	Amount      float64   `json:"amount"`
+	CategoryID  int64     `json:"category_id,omitempty"`
+	AccountID   int64     `json:"account_id"`
+	ToAccountID int64     `json:"to_account_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type exportGoal struct {
+	Name     string  `json:"name"`
+	Target   float64 `json:"target"`
+	Saved    float64 `json:"saved"`
+	Deadline string  `json:"deadline"`
+}
+
+type exportDebt struct {
+	Counterparty string  `json:"counterparty"`
+	Direction    string  `json:"direction"`
+	Amount       float64 `json:"amount"`
+	Repaid       float64 `json:"repaid"`
+	DueDate      string  `json:"due_date,omitempty"`
+}
+
+type exportTemplate struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Amount     float64 `json:"amount"`
+	CategoryID int64   `json:"category_id"`
+	AccountID  int64   `json:"account_id"`
+}
+
+// exportAPIToken tells that an API token exists. Neither the token nor its hash is exported.
+type exportAPIToken struct {
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type exportDigest struct {
+	Period string `json:"period"`
+	Time   string `json:"time"`
+}
+
+type exportReminder struct {
+	Time string `json:"time"`
+}
+
+// buildExport collects everything stored for the chat into a JSON file ready to be sent.
+func buildExport(storageInstance *storage.Storage, chatID int64, now time.Time) (*telebot.Document, error) {
+	user, err := storageInstance.GetUserByChatID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+	categories, err := storageInstance.GetCategoriesByChatID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting categories: %w", err)
+	}
+	accounts, err := storageInstance.GetAccountsByChatID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting accounts: %w", err)
+	}
+	transactions, err := storageInstance.GetTransactionsByChatID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting transactions: %w", err)
+	}
+	goals, err := storageInstance.GetGoalsByChatID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting goals: %w", err)
+	}
+	debts, err := storageInstance.GetDebtsByChatID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting debts: %w", err)
+	}
+	templates, err := storageInstance.GetTemplatesByChatID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting templates: %w", err)
+	}
+	apiToken, err := storageInstance.GetAPIToken(chatID)
+	hasAPIToken := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("error getting API token: %w", err)
+	}
+	digest, err := storageInstance.GetDigestSettingsByChatID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting digest settings: %w", err)
+	}
+	reminder, reminderOn, err := storageInstance.GetReminderSettingsByChatID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reminder settings: %w", err)
+	}
+
+	export := userExport{
+		ExportedAt: now,
+		ChatID:     user.ChatID,
+		Username:   user.Username,
+		Timezone:   user.Timezone,
+		CreatedAt:  user.CreatedAt,
+	}
+	for _, c := range categories {
+		export.Categories = append(export.Categories, exportCategory{
+			ID:       c.ID,
+			ParentID: c.ParentID,
+			Name:     c.Name,
+			Kind:     exportCategoryKind(c.Kind),
+			Icon:     c.Icon,
+		})
+	}
+	for _, a := range accounts {
+		export.Accounts = append(export.Accounts, exportAccount{ID: a.ID, Name: a.Name, OpeningBalance: a.OpeningBalance})
+	}
+	for _, t := range transactions {
+		export.Transactions = append(export.Transactions, exportTransaction{
+			ID:          t.ID,
+			Type:        exportTransactionType(t.TransactionType),
+			Amount:      t.Amount,
+			CategoryID:  t.CategoryID,
+			AccountID:   t.AccountID,
+			ToAccountID: t.ToAccountID,
+			CreatedAt:   t.CreatedAt,
+		})
+	}
+	for _, g := range goals {
+		export.Goals = append(export.Goals, exportGoal{
+			Name:     g.Name,
+			Target:   g.TargetAmount,
+			Saved:    g.Saved,
+			Deadline: g.Deadline.Format(time.DateOnly),
+		})
+	}
+	for _, d := range debts {
+		e := exportDebt{Counterparty: d.Counterparty, Direction: "lent", Amount: d.Amount, Repaid: d.Repaid}
+		if d.Direction == model.DebtDirectionBorrowed {
+			e.Direction = "borrowed"
+		}
+		if !d.DueDate.IsZero() {
+			e.DueDate = d.DueDate.Format(time.DateOnly)
+		}
+		export.Debts = append(export.Debts, e)
+	}
+	for _, t := range templates {
+		export.Templates = append(export.Templates, exportTemplate{
+			Name:       t.Name,
+			Type:       exportTransactionType(t.TransactionType),
+			Amount:     t.Amount,
+			CategoryID: t.CategoryID,
+			AccountID:  t.AccountID,
+		})
+	}
+	if digest.Period != model.DigestPeriodOff {
+		export.Digest = &exportDigest{
+			Period: exportDigestPeriod(digest.Period),
+			Time:   formatReminderTime(digest.SendMinute),
+		}
+	}
+	if reminderOn {
+		export.Reminder = &exportReminder{Time: formatReminderTime(reminder.SendMinute)}
+	}
+	if hasAPIToken {
+		export.APIToken = &exportAPIToken{CreatedAt: apiToken.CreatedAt}
+		if !apiToken.LastUsedAt.IsZero() {
+			export.APIToken.LastUsedAt = &apiToken.LastUsedAt
+		}
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding export: %w", err)
+	}
+	return &telebot.Document{
+		File:     telebot.FromReader(bytes.NewReader(data)),
+		FileName: "budget-bot-export-" + now.Format("2006-01-02") + ".json",
+		MIME:     "application/json",
+	}, nil
+}
+
+func exportCategoryKind(kind uint8) string {
+	switch kind {
+	case model.CategoryKindIncome:
+		return "income"
+	case model.CategoryKindExpense:
+		return "expense"
+	default:
+		return "both"
+	}
+}
+
+func exportTransactionType(transactionType uint8) string {
+	switch transactionType {
+	case model.TransactionTypeIncome:
+		return "income"
+	case model.TransactionTypeExpense:
+		return "expense"
+	default:
+		return "transfer"
+	}
+}
+
+func exportDigestPeriod(period uint8) string {
+	switch period {
+	case model.DigestPeriodDaily:
+		return "daily"
+	case model.DigestPeriodWeekly:
+		return "weekly"
+	default:
+		return "monthly"
+	}
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
+)
+
+const (
+	goalPickerPageSize = 10
+	progressBarWidth   = 10
+)
+
+// goalPickerPage renders a page of goals to attribute the transaction to, see "goalpick:<transaction id>".
+func goalPickerPage(goals []model.Goal, transactionId string, page int, query string) (string, *telebot.ReplyMarkup) {
+	markup := &telebot.ReplyMarkup{}
+
+	var items []telebot.Btn
+	for _, goal := range goals {
+		if !matchesPrefix(goal.Name, query) {
+			continue
+		}
+		items = append(items, markup.Data(goal.Name, "contribute:"+strconv.FormatInt(goal.ID, 10)+":"+transactionId))
+	}
+
+	list := pageList{
+		Items:      items,
+		Columns:    1,
+		PageSize:   goalPickerPageSize,
+		Page:       page,
+		Route:      "goalpick:" + transactionId,
+		Query:      query,
+		Searchable: len(goals) > goalPickerPageSize,
+	}
+	markup.Inline(list.Rows(markup)...)
+
+	text := "Выберите цель:"
+	if len(items) == 0 {
+		text = "Цели не найдены."
+	}
+	return text, markup
+}
+
+// progressBar draws a share between 0 and 1 as a text bar, e.g. "▓▓▓▓░░░░░░ 40%".
+func progressBar(share float64) string {
+	filled := int(share*progressBarWidth + 0.5)
+	filled = min(max(filled, 0), progressBarWidth)
+	return strings.Repeat("▓", filled) + strings.Repeat("░", progressBarWidth-filled) +
+		fmt.Sprintf(" %.0f%%", share*100)
+}
+
+// formatGoal describes the progress of a goal, the pace needed to reach it and a nudge when falling behind.
+func formatGoal(goal model.Goal, now time.Time) string {
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("🎯 %s — до %s\n", goal.Name, goal.Deadline.Format("02.01.2006")))
+	response.WriteString(progressBar(goal.Progress()) + "\n")
+	response.WriteString(fmt.Sprintf("Накоплено %.2f из %.2f\n", goal.Saved, goal.TargetAmount))
+
+	if goal.Remaining() == 0 {
+		response.WriteString("Цель достигнута! 🎉")
+		return response.String()
+	}
+	if !now.Before(goal.Deadline) {
+		response.WriteString(fmt.Sprintf("⚠️ Срок прошёл, осталось накопить %.2f", goal.Remaining()))
+		return response.String()
+	}
+
+	response.WriteString(fmt.Sprintf("Нужно откладывать %.2f в месяц", goal.RequiredMonthlyPace(now)))
+	if goal.IsBehind(now) {
+		response.WriteString(fmt.Sprintf(
+			"\n⚠️ Вы отстаёте: сейчас в среднем %.2f в месяц. Добавьте взнос, чтобы успеть к сроку.",
+			goal.CurrentMonthlyPace(now),
+		))
+	}
+	return response.String()
+}
+
+// behindGoals returns the goals that saving at the current pace would not reach by the deadline.
+func behindGoals(goals []model.Goal, now time.Time) []model.Goal {
+	var behind []model.Goal
+	for _, goal := range goals {
+		if now.Before(goal.Deadline) && goal.IsBehind(now) {
+			behind = append(behind, goal)
+		}
+	}
+	return behind
+}
+
+// goalNudgeText tells the owner of a goal that they are falling behind.
+func goalNudgeText(goal model.Goal, now time.Time) string {
+	return fmt.Sprintf(
+		"⚠️ Цель «%s» отстаёт от графика: нужно откладывать %.2f в месяц, а сейчас в среднем %.2f. "+
+			"Отметьте взнос кнопкой «🎯 В цель» после транзакции, прогресс — в /goals.",
+		goal.Name, goal.RequiredMonthlyPace(now), goal.CurrentMonthlyPace(now),
+	)
+}
+
+func formatGoals(goals []model.Goal, now time.Time) *render.Message {
+	response := render.New()
+	for i, goal := range goals {
+		if i > 0 {
+			response.Line().Line()
+		}
+		response.Text(formatGoal(goal, now))
+	}
+	return response
+}
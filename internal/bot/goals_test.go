@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+func TestBehindGoals(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	goal := func(id int64, saved float64, created, deadline time.Time) model.Goal {
+		return model.Goal{ID: id, Name: "Отпуск", TargetAmount: 1200, Saved: saved, CreatedAt: created, Deadline: deadline}
+	}
+	yearAgo, inHalfYear := now.AddDate(-1, 0, 0), now.AddDate(0, 6, 0)
+
+	goals := []model.Goal{
+		goal(1, 0, now.AddDate(0, -2, 0), inHalfYear),    // nothing saved in two months
+		goal(2, 1200, yearAgo, inHalfYear),               // reached
+		goal(3, 1000, now.AddDate(0, -5, 0), inHalfYear), // 200 a month, 200 needed in six months
+		goal(4, 100, yearAgo, now.AddDate(0, 0, -1)),     // deadline passed
+		goal(5, 100, now.AddDate(0, -3, 0), inHalfYear),  // 33 a month, 183 needed
+	}
+	var ids []int64
+	for _, g := range behindGoals(goals, now) {
+		ids = append(ids, g.ID)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 5 {
+		t.Errorf("behindGoals() = %v, want [1 5]", ids)
+	}
+}
+
+func TestGoalNudgeText(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	g := model.Goal{Name: "Отпуск", TargetAmount: 1200, CreatedAt: now.AddDate(0, -2, 0), Deadline: now.AddDate(0, 6, 0)}
+	text := goalNudgeText(g, now)
+	if !strings.Contains(text, "«Отпуск»") || !strings.Contains(text, "сейчас в среднем 0.00") {
+		t.Errorf("goalNudgeText() = %q", text)
+	}
+}
@@ -1,78 +1,181 @@
 package bot
 
 import (
+	"fmt"
+
 	"github.com/sirupsen/logrus"
 	"gopkg.in/telebot.v3"
 
+	"github.com/cupitman9/budget-bot/internal/config"
 	"github.com/cupitman9/budget-bot/internal/model"
 	"github.com/cupitman9/budget-bot/internal/storage"
 )
 
-var userSessions = make(map[int64]*model.UserSession)
+var userSessions = newSessionStore()
+
+func RegisterHandlers(b *telebot.Bot, cfg *config.Config, storageInstance *storage.Storage, log *logrus.Logger) error {
+	blocked, err := storageInstance.GetBlockedUsers()
+	if err != nil {
+		return fmt.Errorf("error getting blocked users: %w", err)
+	}
+	access := newAccessControl(cfg.Access, blocked)
+	userAccess = access
 
-func RegisterHandlers(b *telebot.Bot, storageInstance *storage.Storage, log *logrus.Logger) {
-	cbHandler := newCallbackHandler(b, storageInstance, log)
-	msgHandler := newMessageHandler(b, storageInstance, log)
+	cbHandler := newCallbackHandler(b, storageInstance, cfg.StatsTopCategories)
+	msgHandler := newMessageHandler(b, storageInstance, cfg.StatsTopCategories)
+	admHandler := newAdminHandler(b, storageInstance, access)
+	inlHandler := newInlineHandler(b, storageInstance, cfg.StatsTopCategories)
+
+	b.Use(
+		withRequestLog(log),
+		recoverPanic(),
+		rateLimit(newRateLimiter(cfg.RateLimit.PerSecond, cfg.RateLimit.Burst)),
+		restrictAccess(access),
+	)
 
 	b.Handle("/start", func(ctx telebot.Context) error {
-		err := msgHandler.handleStart(ctx.Message())
-		if err != nil {
-			log.WithField("userId", ctx.Message().Sender.ID).WithError(err).Error("error handling /start")
-		}
-		return nil
-	})
+		return msgHandler.handleStart(ctx.Message(), requestLog(ctx))
+	}, observe("/start"))
 
 	b.Handle("/help", func(ctx telebot.Context) error {
-		err := msgHandler.handleHelp(ctx.Message())
-		if err != nil {
-			log.WithField("userId", ctx.Message().Sender.ID).WithError(err).Error("error handling /help")
-		}
-		return nil
-	})
+		return msgHandler.handleHelp(ctx.Message())
+	}, observe("/help"))
 
 	b.Handle("/add_category", func(ctx telebot.Context) error {
-		userSessions[ctx.Message().Sender.ID] = &model.UserSession{
+		userSessions.Set(ctx.Message().Sender.ID, &model.UserSession{
 			State: model.StateAwaitingNewCategoryName,
-		}
+		})
 
-		_, err := b.Send(ctx.Sender(), "Введите название новой категории:")
-		if err != nil {
-			log.WithField("userId", ctx.Message().Sender.ID).WithError(err).
-				Error("error handling /add_category")
-		}
-		return nil
-	})
+		err := sendText(b, ctx.Sender(), "Введите название новой категории:", nil)
+		return err
+	}, observe("/add_category"))
 
 	b.Handle("/show_categories", func(ctx telebot.Context) error {
-		err := msgHandler.handleShowCategories(ctx.Message())
-		if err != nil {
-			log.WithField("userId", ctx.Message().Sender.ID).WithError(err).
-				Error("error handling /show_categories")
-		}
-		return nil
-	})
+		return msgHandler.handleShowCategories(ctx.Message(), requestLog(ctx))
+	}, observe("/show_categories"))
+
+	b.Handle("/add_account", func(ctx telebot.Context) error {
+		userSessions.Set(ctx.Message().Sender.ID, &model.UserSession{
+			State: model.StateAwaitingAccountName,
+		})
+
+		err := sendText(b, ctx.Sender(), "Введите название нового счёта:", nil)
+		return err
+	}, observe("/add_account"))
+
+	b.Handle("/balance", func(ctx telebot.Context) error {
+		return msgHandler.handleBalance(ctx.Message())
+	}, observe("/balance"))
+
+	b.Handle("/goal", func(ctx telebot.Context) error {
+		userSessions.Set(ctx.Message().Sender.ID, &model.UserSession{
+			State: model.StateAwaitingGoalName,
+		})
+
+		err := sendText(b, ctx.Sender(), "Введите название цели:", nil)
+		return err
+	}, observe("/goal"))
+
+	b.Handle("/dashboard", func(ctx telebot.Context) error {
+		return msgHandler.handleDashboard(ctx.Message(), cfg.WebApp.PublicURL)
+	}, observe("/dashboard"))
+
+	b.Handle("/api_token", func(ctx telebot.Context) error {
+		return msgHandler.handleAPIToken(ctx.Message(), cfg.APIListen != "")
+	}, observe("/api_token"))
+
+	b.Handle("/goals", func(ctx telebot.Context) error {
+		return msgHandler.handleGoals(ctx.Message())
+	}, observe("/goals"))
+
+	b.Handle("/debt", func(ctx telebot.Context) error {
+		return msgHandler.handleDebt(ctx.Message())
+	}, observe("/debt"))
+
+	b.Handle("/debts", func(ctx telebot.Context) error {
+		return msgHandler.handleDebts(ctx.Message())
+	}, observe("/debts"))
+
+	b.Handle("/digest", func(ctx telebot.Context) error {
+		return msgHandler.handleDigest(ctx.Message())
+	}, observe("/digest"))
+
+	b.Handle("/reminder", func(ctx telebot.Context) error {
+		return msgHandler.handleReminder(ctx.Message())
+	}, observe("/reminder"))
+
+	b.Handle("/timezone", func(ctx telebot.Context) error {
+		userSessions.Set(ctx.Message().Sender.ID, &model.UserSession{
+			State: model.StateAwaitingTimezone,
+		})
+
+		err := sendText(b, ctx.Sender(), "Введите часовой пояс, например Europe/Moscow или +3:", nil)
+		return err
+	}, observe("/timezone"))
 
 	b.Handle("/stats", func(ctx telebot.Context) error {
-		err := msgHandler.handleStatsButtons(ctx.Message())
-		if err != nil {
-			log.WithField("userId", ctx.Message().Sender.ID).WithError(err).Error("error handling /stats")
-		}
-		return nil
-	})
+		return msgHandler.handleStatsButtons(ctx.Message())
+	}, observe("/stats"))
+
+	b.Handle("/undo", func(ctx telebot.Context) error {
+		return msgHandler.handleUndo(ctx.Message())
+	}, observe("/undo"))
+
+	b.Handle("/history", func(ctx telebot.Context) error {
+		return msgHandler.handleHistory(ctx.Message())
+	}, observe("/history"))
+
+	b.Handle("/templates", func(ctx telebot.Context) error {
+		return msgHandler.handleTemplates(ctx.Message())
+	}, observe("/templates"))
+
+	b.Handle("/keyboard", func(ctx telebot.Context) error {
+		return msgHandler.handleKeyboard(ctx.Message())
+	}, observe("/keyboard"))
+
+	b.Handle("/reset", func(ctx telebot.Context) error {
+		return msgHandler.handleReset(ctx.Message())
+	}, observe("/reset"))
+
+	b.Handle("/delete_me", func(ctx telebot.Context) error {
+		return msgHandler.handleDeleteMe(ctx.Message())
+	}, observe("/delete_me"))
+
+	b.Handle("/admin_stats", func(ctx telebot.Context) error {
+		return admHandler.handleStats(ctx.Message())
+	}, observe("/admin_stats"), adminOnly(access))
+
+	b.Handle("/admin_broadcast", func(ctx telebot.Context) error {
+		return admHandler.handleBroadcast(ctx.Message(), requestLog(ctx))
+	}, observe("/admin_broadcast"), adminOnly(access))
+
+	b.Handle("/admin_block", func(ctx telebot.Context) error {
+		return admHandler.handleBlock(ctx.Message(), true)
+	}, observe("/admin_block"), adminOnly(access))
+
+	b.Handle("/admin_unblock", func(ctx telebot.Context) error {
+		return admHandler.handleBlock(ctx.Message(), false)
+	}, observe("/admin_unblock"), adminOnly(access))
 
 	b.Handle(telebot.OnText, func(ctx telebot.Context) error {
-		err := msgHandler.handleOnText(ctx.Message())
-		if err != nil {
-			log.WithField("userId", ctx.Message().Sender.ID).WithError(err).Error("error handling text")
-		}
-		return nil
-	})
+		return msgHandler.handleOnText(ctx.Message(), requestLog(ctx))
+	}, observe("text"))
+
+	b.Handle(telebot.OnPhoto, func(ctx telebot.Context) error {
+		return msgHandler.handlePhoto(ctx.Message())
+	}, observe("photo"))
 
 	b.Handle(telebot.OnCallback, func(ctx telebot.Context) error {
-		err := cbHandler.handleCallback(ctx.Callback())
-		if err != nil {
-			log.WithField("userId", ctx.Message().Sender.ID).WithError(err).Error("error handling callback")
-		}
-		return nil
-	})
+		return cbHandler.handleCallback(ctx.Callback(), requestLog(ctx))
+	}, observe("callback"))
+
+	b.Handle(telebot.OnQuery, func(ctx telebot.Context) error {
+		return inlHandler.handleQuery(ctx.Query())
+	}, observe("inline_query"))
+
+	b.Handle(telebot.OnInlineResult, func(ctx telebot.Context) error {
+		return inlHandler.handleResult(ctx.InlineResult(), requestLog(ctx))
+	}, observe("inline_result"))
+
+	return nil
 }
@@ -0,0 +1,206 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+const (
+	historyPageSize = 15
+	// historyMaxEntries bounds the changes paged through, the oldest ones are not shown.
+	historyMaxEntries = 300
+)
+
+// buildHistory renders a page of the latest changes of the chat, of a single entity unless entity is empty,
+// with the buttons paging through them. The pages are the "histlog:<entity>:<entity id>" listing.
+func buildHistory(
+	storageInstance *storage.Storage,
+	chatID int64,
+	entity string,
+	entityID int64,
+	page int,
+) (*render.Message, *telebot.ReplyMarkup, error) {
+	user, err := storageInstance.GetUserByChatID(chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	categories, err := storageInstance.GetCategoriesByChatID(chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := storageInstance.GetAuditLog(chatID, entity, entityID, historyMaxEntries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make(map[int64]string, len(categories))
+	for _, category := range categories {
+		names[category.ID] = category.Name
+	}
+
+	title := "Последние изменения:"
+	switch entity {
+	case model.AuditEntityCategory:
+		title = "История категории " + categoryName(names, entityID) + ":"
+	case model.AuditEntityTransaction:
+		title = fmt.Sprintf("История транзакции #%d:", entityID)
+	}
+
+	list := pageList{
+		Count:    len(entries),
+		PageSize: historyPageSize,
+		Page:     page,
+		Route:    "histlog:" + entity + ":" + strconv.FormatInt(entityID, 10),
+	}
+	page = min(max(page, 0), list.Pages()-1)
+	end := min((page+1)*historyPageSize, len(entries))
+	start := min(page*historyPageSize, end)
+
+	markup := &telebot.ReplyMarkup{}
+	if rows := list.Rows(markup); len(rows) > 0 {
+		markup.Inline(rows...)
+	} else {
+		markup = nil
+	}
+	return formatHistory(title, entries[start:end], names, user.Location()), markup, nil
+}
+
+// formatHistory renders audit entries, newest first, in the user's time zone. Category names are looked
+// up in names so that transactions show their categories.
+func formatHistory(title string, entries []model.AuditEntry, names map[int64]string, loc *time.Location) *render.Message {
+	response := render.New().Text(title).Line()
+	if len(entries) == 0 {
+		return response.Text("Изменений нет.")
+	}
+
+	for _, e := range entries {
+		response.Text(e.CreatedAt.In(loc).Format("02.01 15:04") + " · " + describeAuditEntry(e, names) +
+			auditSourceText(e.Source)).Line()
+	}
+	return response
+}
+
+func describeAuditEntry(e model.AuditEntry, names map[int64]string) string {
+	if e.Entity == model.AuditEntityCategory {
+		return describeCategoryChange(e, names)
+	}
+	return describeTransactionChange(e, names)
+}
+
+// auditSourceText marks changes made outside the bot.
+func auditSourceText(source string) string {
+	switch source {
+	case model.AuditSourceWebApp:
+		return " (Mini App)"
+	case model.AuditSourceAPI:
+		return " (API)"
+	default:
+		return ""
+	}
+}
+
+func describeCategoryChange(e model.AuditEntry, names map[int64]string) string {
+	switch e.Operation {
+	case model.AuditOperationInsert:
+		return "создана категория «" + auditString(e.After, "name") + "»"
+	case model.AuditOperationDelete:
+		return "удалена категория «" + auditString(e.Before, "name") + "»"
+	}
+
+	var changes []string
+	if before, after := auditString(e.Before, "name"), auditString(e.After, "name"); before != after {
+		changes = append(changes, "название «"+before+"» → «"+after+"»")
+	}
+	if before, after := auditInt(e.Before, "kind"), auditInt(e.After, "kind"); before != after {
+		changes = append(changes, "тип "+categoryKindText(uint8(before))+" → "+categoryKindText(uint8(after)))
+	}
+	if before, after := auditString(e.Before, "icon"), auditString(e.After, "icon"); before != after {
+		changes = append(changes, "иконка «"+before+"» → «"+after+"»")
+	}
+	if before, after := auditInt(e.Before, "parent_id"), auditInt(e.After, "parent_id"); before != after {
+		changes = append(changes, "родитель "+categoryName(names, before)+" → "+categoryName(names, after))
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "изменена")
+	}
+	return "категория «" + auditString(e.After, "name") + "»: " + strings.Join(changes, ", ")
+}
+
+func describeTransactionChange(e model.AuditEntry, names map[int64]string) string {
+	row := e.After
+	if e.Operation == model.AuditOperationDelete {
+		row = e.Before
+	}
+	subject := fmt.Sprintf("%s %.2f #%d", transactionTypeText(uint8(auditInt(row, "transaction_type"))),
+		auditFloat(row, "amount"), e.EntityID)
+	if categoryID := auditInt(row, "category_id"); categoryID != 0 {
+		subject += " (" + categoryName(names, categoryID) + ")"
+	}
+
+	switch e.Operation {
+	case model.AuditOperationInsert:
+		return "добавлен " + subject
+	case model.AuditOperationDelete:
+		return "удалён " + subject
+	}
+
+	var changes []string
+	if before, after := auditFloat(e.Before, "amount"), auditFloat(e.After, "amount"); before != after {
+		changes = append(changes, fmt.Sprintf("сумма %.2f → %.2f", before, after))
+	}
+	if before, after := auditInt(e.Before, "category_id"), auditInt(e.After, "category_id"); before != after {
+		changes = append(changes, "категория "+categoryName(names, before)+" → "+categoryName(names, after))
+	}
+	if before, after := auditString(e.Before, "created_at"), auditString(e.After, "created_at"); before != after {
+		changes = append(changes, "дата изменена")
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "изменён")
+	}
+	return subject + ": " + strings.Join(changes, ", ")
+}
+
+func transactionTypeText(transactionType uint8) string {
+	switch transactionType {
+	case model.TransactionTypeIncome:
+		return "доход"
+	case model.TransactionTypeExpense:
+		return "расход"
+	default:
+		return "перевод"
+	}
+}
+
+func categoryName(names map[int64]string, id int64) string {
+	if id == 0 {
+		return "—"
+	}
+	if name, ok := names[id]; ok {
+		return "«" + name + "»"
+	}
+	return "#" + strconv.FormatInt(id, 10)
+}
+
+// Row values of audit entries come from JSON: numbers are float64 and absent or null values are zero.
+
+func auditString(row map[string]any, key string) string {
+	value, _ := row[key].(string)
+	return value
+}
+
+func auditFloat(row map[string]any, key string) float64 {
+	value, _ := row[key].(float64)
+	return value
+}
+
+func auditInt(row map[string]any, key string) int64 {
+	return int64(auditFloat(row, key))
+}
@@ -0,0 +1,225 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+const (
+	inlineResultLimit = 10
+	inlineStatsID     = "stats"
+	// inlineAmountMaxLength keeps the amount within the 64 bytes Telegram allows for a result ID.
+	inlineAmountMaxLength = 16
+	// inlineCacheTime is the shortest cache time in seconds. Zero is left out of the request, and Telegram
+	// then caches results for five minutes.
+	inlineCacheTime = 1
+)
+
+// inlineStatsQueries are the queries that offer the stats card.
+var inlineStatsQueries = map[string]bool{"stats": true, "статистика": true}
+
+// inlineHandler serves inline mode: "@bot 350 кофе" offers the sender's matching categories and books the
+// expense once a result is chosen, "@bot stats" offers a card with the stats of the month. Results are
+// personal and cached for a second at most, and nothing is booked or posted unless the user picks a result.
+// Booking relies on chosen inline result feedback, which has to be enabled for the bot in BotFather.
+type inlineHandler struct {
+	b               *telebot.Bot
+	storageInstance *storage.Storage
+	statsTop        int
+}
+
+func newInlineHandler(b *telebot.Bot, storageInstance *storage.Storage, statsTop int) *inlineHandler {
+	return &inlineHandler{b: b, storageInstance: storageInstance, statsTop: statsTop}
+}
+
+// handleQuery answers an inline query with a result per matching category or with the stats card.
+// A leading "+" books income instead of an expense.
+func (h *inlineHandler) handleQuery(q *telebot.Query) error {
+	response := &telebot.QueryResponse{IsPersonal: true, CacheTime: inlineCacheTime}
+	fields := strings.Fields(q.Text)
+
+	var err error
+	switch {
+	case len(fields) == 0:
+	case inlineStatsQueries[strings.ToLower(fields[0])]:
+		response.Results, err = h.statsResults(q.Sender.ID)
+	default:
+		response.Results, err = h.transactionResults(q.Sender.ID, fields[0], strings.Join(fields[1:], " "))
+	}
+	if err != nil {
+		return err
+	}
+	if len(response.Results) == 0 {
+		response.SwitchPMText = "Введите сумму и категорию, например «350 кофе»"
+		response.SwitchPMParameter = "inline"
+	}
+	return h.b.Answer(q, response)
+}
+
+func (h *inlineHandler) transactionResults(chatID int64, amountField, query string) (telebot.Results, error) {
+	amountText, transactionType, ok := parseInlineAmount(amountField)
+	if !ok {
+		return nil, nil
+	}
+	categories, err := h.storageInstance.GetCategoriesByKind(chatID, transactionType)
+	if err != nil {
+		return nil, err
+	}
+	return transactionCards(categories, transactionType, amountText, query), nil
+}
+
+// parseInlineAmount reads the amount field of a query, "350" for an expense or "+350" for income, and returns
+// the amount as it goes into the result ID.
+func parseInlineAmount(field string) (string, uint8, bool) {
+	transactionType := model.TransactionTypeExpense
+	if strings.HasPrefix(field, "+") {
+		transactionType = model.TransactionTypeIncome
+	}
+	amount, err := model.ParseAmount(strings.TrimPrefix(field, "+"))
+	amountText := strconv.FormatFloat(amount, 'f', -1, 64)
+	if err != nil || len(amountText) > inlineAmountMaxLength {
+		return "", 0, false
+	}
+	return amountText, transactionType, true
+}
+
+// transactionCards offers the categories whose names match query, in their order, as results booking
+// the amount. The result ID is parsed back by parseInlineResultID.
+func transactionCards(categories []model.Category, transactionType uint8, amountText, query string) telebot.Results {
+	tree := newCategoryTree(categories)
+
+	icon := "💸"
+	if transactionType == model.TransactionTypeIncome {
+		icon = "💰"
+	}
+
+	var results telebot.Results
+	for _, category := range categories {
+		if len(results) == inlineResultLimit {
+			break
+		}
+		if !matchesPrefix(category.Name, query) {
+			continue
+		}
+		path := tree.path(category.ID)
+		result := &telebot.ArticleResult{
+			Title:       fmt.Sprintf("%s %s — %s", icon, amountText, path),
+			Description: "Записать " + transactionTypeText(transactionType),
+		}
+		result.ID = fmt.Sprintf("tx:%d:%d:%s", transactionType, category.ID, amountText)
+		result.Content = &telebot.InputTextMessageContent{Text: fmt.Sprintf("%s %s — %s", icon, amountText, path)}
+		results = append(results, result)
+	}
+	return results
+}
+
+// statsResults offers the stats of the current month as a card. Its content reaches the chat only if the
+// user picks it.
+func (h *inlineHandler) statsResults(chatID int64) (telebot.Results, error) {
+	now := time.Now()
+	startDate := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	stats, _, err := buildStats(h.storageInstance, chatID, startDate, startDate.AddDate(0, 1, 0), false, h.statsTop)
+	if err != nil {
+		return nil, err
+	}
+	return telebot.Results{statsCard(stats)}, nil
+}
+
+// statsCard is the shareable stats card. A message of a chosen result cannot be split, so a card longer
+// than a message is cut to its first part.
+func statsCard(stats *render.Message) *telebot.ArticleResult {
+	result := &telebot.ArticleResult{
+		Title:       "📊 Статистика за месяц",
+		Description: "Отправить сводку доходов и расходов в этот чат",
+	}
+	result.ID = inlineStatsID
+	result.Content = &telebot.InputTextMessageContent{Text: stats.Parts()[0], ParseMode: render.ParseMode}
+	return result
+}
+
+// handleResult books the transaction of a chosen result from "tx:<type>:<category id>:<amount>" and
+// confirms it in the private chat with an undo button. The transaction goes to the account of the last
+// transaction, or to the first account.
+func (h *inlineHandler) handleResult(r *telebot.InlineResult, log *logrus.Entry) error {
+	if r.ResultID == inlineStatsID {
+		return nil
+	}
+	transactionType, categoryID, amount, err := parseInlineResultID(r.ResultID)
+	if err != nil {
+		return err
+	}
+
+	// handleTransaction checks the category and its kind within the sender's ledger, so a forged result can
+	// only book to their own; the category is loaded here for its label.
+	category, err := h.storageInstance.GetCategoryByID(r.Sender.ID, categoryID)
+	if err != nil {
+		return err
+	}
+	accountID, err := h.inlineAccountID(r.Sender.ID)
+	if err != nil {
+		sendErr := sendText(h.b, r.Sender, "Не удалось записать транзакцию: добавьте счёт командой /add_account.", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	transactionId, err := handleTransaction(h.storageInstance, r.Sender.ID, accountID, categoryID, amount,
+		transactionType, time.Time{})
+	if err != nil {
+		sendErr := sendText(h.b, r.Sender, transactionErrorText(err), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	text := fmt.Sprintf("Транзакция на сумму %s добавлена в категорию %s",
+		strconv.FormatFloat(amount, 'f', -1, 64), category.Label())
+	err = sendText(h.b, r.Sender, text, transactionMarkup(h.storageInstance, log, r.Sender.ID, transactionId, true))
+	return err
+}
+
+// parseInlineResultID reads "tx:<type>:<category id>:<amount>" built by transactionCards.
+func parseInlineResultID(id string) (uint8, int64, float64, error) {
+	parts := strings.Split(id, ":")
+	if len(parts) != 4 || parts[0] != "tx" {
+		return 0, 0, 0, fmt.Errorf("unexpected inline result ID with prefix %q", parts[0])
+	}
+	transactionType, errType := strconv.ParseUint(parts[1], 10, 8)
+	categoryID, errCategory := parseCategoryId(parts[2])
+	amount, errAmount := model.ParseAmount(parts[3])
+	if err := errors.Join(errType, errCategory, errAmount); err != nil {
+		return 0, 0, 0, err
+	}
+	return uint8(transactionType), categoryID, amount, nil
+}
+
+func (h *inlineHandler) inlineAccountID(chatID int64) (int64, error) {
+	last, err := h.storageInstance.GetLastTransaction(chatID)
+	if err == nil {
+		return last.AccountID, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+	accounts, err := h.storageInstance.GetAccountsByChatID(chatID)
+	if err != nil {
+		return 0, err
+	}
+	if len(accounts) == 0 {
+		return 0, errors.New("no accounts")
+	}
+	return accounts[0].ID, nil
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
+)
+
+func TestParseInlineAmount(t *testing.T) {
+	tests := []struct {
+		field    string
+		wantText string
+		wantType uint8
+		wantOK   bool
+	}{
+		{"350", "350", model.TransactionTypeExpense, true},
+		{"350,50", "350.5", model.TransactionTypeExpense, true},
+		{"0.01", "0.01", model.TransactionTypeExpense, true},
+		{"+1000", "1000", model.TransactionTypeIncome, true},
+		{"+12,5", "12.5", model.TransactionTypeIncome, true},
+		{"0", "", 0, false},
+		{"-350", "", 0, false},
+		{"+-350", "", 0, false},
+		{"кофе", "", 0, false},
+		{"NaN", "", 0, false},
+		{"Inf", "", 0, false},
+		// longer than a result ID can carry
+		{"12345678901234567", "", 0, false},
+		{"0.000000000000001", "", 0, false},
+	}
+	for _, tt := range tests {
+		text, transactionType, ok := parseInlineAmount(tt.field)
+		if text != tt.wantText || transactionType != tt.wantType || ok != tt.wantOK {
+			t.Errorf("parseInlineAmount(%q) = %q, %d, %v, want %q, %d, %v",
+				tt.field, text, transactionType, ok, tt.wantText, tt.wantType, tt.wantOK)
+		}
+	}
+}
+
+func TestTransactionCards(t *testing.T) {
+	categories := []model.Category{
+		{ID: 1, Name: "Кофе"},
+		{ID: 2, Name: "Кафе"},
+		{ID: 3, Name: "Транспорт"},
+		{ID: 4, Name: "Каршеринг", ParentID: 3},
+		{ID: 5, Name: "Еда вне дома"},
+	}
+
+	tests := []struct {
+		name            string
+		transactionType uint8
+		query           string
+		want            []string // IDs and titles
+	}{
+		{
+			name: "prefix of any word", transactionType: model.TransactionTypeExpense, query: "ка",
+			want: []string{"tx:2:2:350 💸 350 — Кафе", "tx:2:4:350 💸 350 — Транспорт → Каршеринг"},
+		},
+		{
+			name: "ignores case", transactionType: model.TransactionTypeExpense, query: "ДОМА",
+			want: []string{"tx:2:5:350 💸 350 — Еда вне дома"},
+		},
+		{
+			name: "income", transactionType: model.TransactionTypeIncome, query: "кофе",
+			want: []string{"tx:1:1:350 💰 350 — Кофе"},
+		},
+		{
+			name: "no match", transactionType: model.TransactionTypeExpense, query: "зарплата",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range transactionCards(categories, tt.transactionType, "350", tt.query) {
+				article := r.(*telebot.ArticleResult)
+				content := article.Content.(*telebot.InputTextMessageContent)
+				if content.Text != article.Title {
+					t.Errorf("content %q differs from title %q", content.Text, article.Title)
+				}
+				got = append(got, article.ID+" "+article.Title)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("transactionCards() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransactionCardsLimit(t *testing.T) {
+	var categories []model.Category
+	for i := 1; i <= inlineResultLimit+5; i++ {
+		categories = append(categories, model.Category{ID: int64(i), Name: fmt.Sprintf("Категория %d", i)})
+	}
+	results := transactionCards(categories, model.TransactionTypeExpense, "1", "")
+	if len(results) != inlineResultLimit {
+		t.Fatalf("transactionCards() returned %d results, want %d", len(results), inlineResultLimit)
+	}
+	// the longest amount still fits into the 64 bytes of a result ID
+	amountText := strings.Repeat("9", inlineAmountMaxLength)
+	id := transactionCards(categories, model.TransactionTypeIncome, amountText, "")[0].ResultID()
+	if len(id) > 64 {
+		t.Errorf("result ID %q is %d bytes long", id, len(id))
+	}
+}
+
+func TestParseInlineResultID(t *testing.T) {
+	transactionType, categoryID, amount, err := parseInlineResultID("tx:1:42:350.5")
+	if err != nil || transactionType != model.TransactionTypeIncome || categoryID != 42 || amount != 350.5 {
+		t.Errorf("parseInlineResultID() = %d, %d, %v, %v", transactionType, categoryID, amount, err)
+	}
+
+	for _, id := range []string{
+		"stats", "tx:1:42", "tx:1:42:350:1", "rx:1:42:350", "tx:x:42:350", "tx:1:x:350", "tx:1:42:-350",
+	} {
+		if _, _, _, err := parseInlineResultID(id); err == nil {
+			t.Errorf("parseInlineResultID(%q) succeeded", id)
+		}
+	}
+}
+
+func TestStatsCard(t *testing.T) {
+	stats := render.New().Text("📊 ").Bold("Статистика за период").Line().Textf("  - %s: %.1f", "Кафе & бары", 50.0)
+	card := statsCard(stats)
+	content := card.Content.(*telebot.InputTextMessageContent)
+	want := "📊 <b>Статистика за период</b>\n  - Кафе &amp; бары: 50.0"
+	if card.ID != inlineStatsID || content.Text != want || content.ParseMode != render.ParseMode {
+		t.Errorf("statsCard() = %q %q %q", card.ID, content.Text, content.ParseMode)
+	}
+
+	long := render.New()
+	for i := 0; i < 500; i++ {
+		long.Bold("Еда").Text(": 400.0").Line()
+	}
+	content = statsCard(long).Content.(*telebot.InputTextMessageContent)
+	if content.Text != long.Parts()[0] || len(long.Parts()) < 2 {
+		t.Errorf("statsCard() of a long message is not its first part")
+	}
+}
@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+const (
+	digestInterval       = time.Minute
+	reminderInterval     = time.Minute
+	debtReminderInterval = time.Hour
+	goalNudgeInterval    = time.Hour
+	journalPruneInterval = time.Hour
+	// notifyHour keeps debt reminders and goal nudges from arriving at night right after the date changes.
+	// It is the hour in the user's time zone.
+	notifyHour = 10
+	// goalNudgePeriod is how often the owner of a goal that is behind pace is told about it. A goal younger
+	// than that is not judged yet.
+	goalNudgePeriod = 7 * 24 * time.Hour
+	// backgroundMessagesPerSecond stays below the Telegram limit of about 30 messages per second to different
+	// chats. It is shared by the background jobs and admin broadcasts.
+	backgroundMessagesPerSecond = 25
+)
+
+// backgroundTick paces every throttle, so that jobs and broadcasts running at once share the limit.
+var backgroundTick = time.NewTicker(time.Second / backgroundMessagesPerSecond)
+
+// StartJobs runs the periodic background jobs of the bot until ctx is cancelled. statsTop is the number of
+// categories the digests list before "Прочее".
+func StartJobs(ctx context.Context, b *telebot.Bot, storageInstance *storage.Storage, statsTop int, log *logrus.Logger) {
+	t := newThrottle(b)
+
+	go runEvery(ctx, digestInterval, func(now time.Time) {
+		sendDigests(t, storageInstance, statsTop, log, now)
+	})
+	go runEvery(ctx, reminderInterval, func(now time.Time) {
+		sendReminders(t, storageInstance, log, now)
+	})
+	go runEvery(ctx, debtReminderInterval, func(now time.Time) {
+		sendDebtReminders(t, storageInstance, log, now)
+	})
+	go runEvery(ctx, goalNudgeInterval, func(now time.Time) {
+		sendGoalNudges(t, storageInstance, log, now)
+	})
+	go runEvery(ctx, journalPruneInterval, func(now time.Time) {
+		if err := storageInstance.DeleteJournalBefore(now.Add(-undoWindow)); err != nil {
+			log.WithError(err).Error("error pruning action journal")
+		}
+	})
+}
+
+// runEvery calls job right away and then on every tick.
+func runEvery(ctx context.Context, interval time.Duration, job func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	job(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			job(now)
+		}
+	}
+}
+
+// throttle paces the messages sent by background jobs, which may be due for many users at the same
+// minute, and by admin broadcasts. It waits out flood errors once before giving up. All throttles take turns
+// on backgroundTick.
+type throttle struct {
+	b    *telebot.Bot
+	tick *time.Ticker
+}
+
+func newThrottle(b *telebot.Bot) *throttle {
+	return &throttle{b: b, tick: backgroundTick}
+}
+
+func (t *throttle) Send(to telebot.Recipient, what interface{}, opts ...interface{}) (*telebot.Message, error) {
+	<-t.tick.C
+	msg, err := t.b.Send(to, what, opts...)
+
+	var floodErr telebot.FloodError
+	if errors.As(err, &floodErr) {
+		time.Sleep(time.Duration(floodErr.RetryAfter) * time.Second)
+		msg, err = t.b.Send(to, what, opts...)
+	}
+	return msg, err
+}
+
+func sendDebtReminders(t *throttle, storageInstance *storage.Storage, log *logrus.Logger, now time.Time) {
+	// A day ahead, as users east of the server may already live on the next date.
+	debts, err := storageInstance.GetDueDebts(now.AddDate(0, 0, 1))
+	if err != nil {
+		log.WithError(err).Error("error getting due debts")
+		return
+	}
+
+	locations := newUserLocations(storageInstance)
+	for _, debt := range debts {
+		loc, err := locations.get(debt.ChatID)
+		if err != nil {
+			log.WithField("chat_id", debt.ChatID).WithError(err).Error("error getting user time zone")
+			continue
+		}
+		if !isNotifyTime(now, loc) || debt.DueDate.After(dateOf(now.In(loc))) {
+			continue
+		}
+		if err := sendText(t, telebot.ChatID(debt.ChatID), debtReminderText(debt), nil); err != nil {
+			log.WithField("chat_id", debt.ChatID).WithError(err).Error("error sending debt reminder")
+			continue
+		}
+		if err := storageInstance.MarkDebtReminded(debt.ID); err != nil {
+			log.WithField("debt_id", debt.ID).WithError(err).Error("error marking debt reminded")
+		}
+	}
+}
+
+func sendGoalNudges(t *throttle, storageInstance *storage.Storage, log *logrus.Logger, now time.Time) {
+	goals, err := storageInstance.GetGoalsToNudge(now, now.Add(-goalNudgePeriod))
+	if err != nil {
+		log.WithError(err).Error("error getting goals to nudge")
+		return
+	}
+
+	locations := newUserLocations(storageInstance)
+	for _, goal := range behindGoals(goals, now) {
+		loc, err := locations.get(goal.ChatID)
+		if err != nil {
+			log.WithField("chat_id", goal.ChatID).WithError(err).Error("error getting user time zone")
+			continue
+		}
+		if !isNotifyTime(now, loc) {
+			continue
+		}
+		if err := sendText(t, telebot.ChatID(goal.ChatID), goalNudgeText(goal, now), nil); err != nil {
+			log.WithField("chat_id", goal.ChatID).WithError(err).Error("error sending goal nudge")
+			continue
+		}
+		if err := storageInstance.MarkGoalNudged(goal.ID); err != nil {
+			log.WithField("goal_id", goal.ID).WithError(err).Error("error marking goal nudged")
+		}
+	}
+}
+
+// isNotifyTime reports whether notifyHour has come in the time zone of the user.
+func isNotifyTime(now time.Time, loc *time.Location) bool {
+	return now.In(loc).Hour() >= notifyHour
+}
+
+// userLocations looks up the time zones of the users a job writes to, each user once per run.
+type userLocations struct {
+	storageInstance *storage.Storage
+	byChat          map[int64]*time.Location
+}
+
+func newUserLocations(storageInstance *storage.Storage) *userLocations {
+	return &userLocations{storageInstance: storageInstance, byChat: make(map[int64]*time.Location)}
+}
+
+// get returns the time zone of the user, the default one when the chat has no user.
+func (l *userLocations) get(chatID int64) (*time.Location, error) {
+	if loc, ok := l.byChat[chatID]; ok {
+		return loc, nil
+	}
+	user, err := l.storageInstance.GetUserByChatID(chatID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		user = model.User{}
+	} else if err != nil {
+		return nil, err
+	}
+	loc := user.Location()
+	l.byChat[chatID] = loc
+	return loc, nil
+}
@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsNotifyTime(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	newYork := time.FixedZone("EDT", -4*60*60)
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		loc  *time.Location
+		want bool
+	}{
+		{"before the hour in the user zone", time.Date(2024, 6, 1, 6, 59, 0, 0, time.UTC), moscow, false},
+		{"the hour in the user zone", time.Date(2024, 6, 1, 7, 0, 0, 0, time.UTC), moscow, true},
+		// 10:00 UTC is still night in New York
+		{"server hour passed, user hour not", time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC), newYork, false},
+		{"user hour passed, server hour not", time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC), tokyo, true},
+		{"evening of the user", time.Date(2024, 6, 1, 20, 0, 0, 0, time.UTC), moscow, true},
+	}
+	for _, tt := range tests {
+		if got := isNotifyTime(tt.now, tt.loc); got != tt.want {
+			t.Errorf("%s: isNotifyTime(%v, %v) = %v, want %v", tt.name, tt.now, tt.loc, got, tt.want)
+		}
+	}
+}
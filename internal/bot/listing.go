@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/render"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+const (
+	categoryListPageSize   = 10
+	categoryPickerPageSize = 12
+)
+
+// listingRouteParts is the number of callback data parts forming the route of each paginated listing.
+// The parts after the route are the page and the search query.
+var listingRouteParts = map[string]int{
+	"categories": 1,
+	"subcat":     4,
+	"accpick":    4,
+	"trfrom":     2,
+	"trto":       3,
+	"goalpick":   2,
+	"debts":      1,
+	"histlog":    3,
+}
+
+// splitListingData splits callback data of a paginated listing into its route, page and query.
+func splitListingData(prefixes []string) ([]string, int, string, error) {
+	parts, ok := listingRouteParts[prefixes[0]]
+	if !ok || len(prefixes) < parts {
+		// Routes carry amounts, so only the prefix is reported.
+		return nil, 0, "", fmt.Errorf("unexpected %q listing callback data", prefixes[0])
+	}
+	page, query := parsePageArgs(prefixes[parts:])
+	return prefixes[:parts], page, query, nil
+}
+
+// renderListing rebuilds a page of a paginated listing from its route.
+func renderListing(
+	storageInstance *storage.Storage,
+	chatID int64,
+	route []string,
+	page int,
+	query string,
+) (*render.Message, *telebot.ReplyMarkup, error) {
+	switch route[0] {
+	case "categories":
+		categories, err := storageInstance.GetCategoriesByChatID(chatID)
+		if err != nil {
+			return nil, nil, err
+		}
+		text, markup := categoryListPage(categories, page, query)
+		return render.New().Text(text), markup, nil
+	case "subcat":
+		parentID, err := parseCategoryId(route[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		transactionType, err := strconv.ParseUint(route[2], 10, 8)
+		if err != nil {
+			return nil, nil, err
+		}
+		categories, err := storageInstance.GetCategoriesByKind(chatID, uint8(transactionType))
+		if err != nil {
+			return nil, nil, err
+		}
+		text, markup := categoryPickerPage(categories, parentID, route[2]+":"+route[3], page, query)
+		return render.New().Text(text), markup, nil
+	case "accpick", "trfrom", "trto":
+		accounts, err := storageInstance.GetAccountsByChatID(chatID)
+		if err != nil {
+			return nil, nil, err
+		}
+		text, markup := accountPickerPage(accounts, route, page, query)
+		return render.New().Text(text), markup, nil
+	case "goalpick":
+		goals, err := storageInstance.GetGoalsByChatID(chatID)
+		if err != nil {
+			return nil, nil, err
+		}
+		text, markup := goalPickerPage(goals, route[1], page, query)
+		return render.New().Text(text), markup, nil
+	case "debts":
+		debts, err := storageInstance.GetOpenDebts(chatID)
+		if err != nil {
+			return nil, nil, err
+		}
+		text, markup := debtListPage(debts, page, query)
+		return render.New().Text(text), markup, nil
+	case "histlog":
+		entityID, err := strconv.ParseInt(route[2], 10, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		return buildHistory(storageInstance, chatID, route[1], entityID, page)
+	default:
+		return nil, nil, fmt.Errorf("unknown listing %q", route[0])
+	}
+}
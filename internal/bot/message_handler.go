@@ -1,30 +1,41 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
+	"image"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/telebot.v3"
 
+	"github.com/cupitman9/budget-bot/internal/api"
 	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
 	"github.com/cupitman9/budget-bot/internal/storage"
 )
 
 type messageHandler struct {
 	b               *telebot.Bot
 	storageInstance *storage.Storage
-	log             *logrus.Logger
+	// statsTop is the number of categories listed in stats before "Прочее".
+	statsTop int
 }
 
-func newMessageHandler(b *telebot.Bot, storageInstance *storage.Storage, log *logrus.Logger) *messageHandler {
-	return &messageHandler{b: b, storageInstance: storageInstance, log: log}
+func newMessageHandler(b *telebot.Bot, storageInstance *storage.Storage, statsTop int) *messageHandler {
+	return &messageHandler{b: b, storageInstance: storageInstance, statsTop: statsTop}
 }
 
-func (h *messageHandler) handleOnText(m *telebot.Message) error {
-	if _, err := strconv.ParseFloat(m.Text, 64); err == nil {
+func (h *messageHandler) handleOnText(m *telebot.Message, log *logrus.Entry) error {
+	if handled, err := h.handleQuickButton(m, log); handled {
+		return err
+	}
+
+	session, ok := userSessions.Get(m.Sender.ID)
+	if _, err := strconv.ParseFloat(m.Text, 64); err == nil && !(ok && expectsNumber(session.State)) {
 		expErr := h.handleIncomeExpenseButtons(m)
 		if expErr != nil {
 			return fmt.Errorf("%v: %w", err, expErr)
@@ -32,7 +43,6 @@ func (h *messageHandler) handleOnText(m *telebot.Message) error {
 		return err
 	}
 
-	session, ok := userSessions[m.Sender.ID]
 	if ok {
 		switch session.State {
 		case model.StateAwaitingRenameCategory:
@@ -42,7 +52,103 @@ func (h *messageHandler) handleOnText(m *telebot.Message) error {
 			}
 			return nil
 		case model.StateAwaitingNewCategoryName:
-			err := h.handleAwaitingNewCategoryName(m)
+			err := h.handleAwaitingNewCategoryName(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingCategoryIcon:
+			err := h.handleAwaitingCategoryIcon(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingSearchQuery:
+			err := h.handleSearchQuery(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingAccountName:
+			err := h.handleAwaitingAccountName(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingAccountBalance:
+			err := h.handleAwaitingAccountBalance(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingGoalName:
+			err := h.handleAwaitingGoalName(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingGoalTarget:
+			err := h.handleAwaitingGoalTarget(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingGoalDeadline:
+			err := h.handleAwaitingGoalDeadline(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingDebtCounterparty:
+			err := h.handleAwaitingDebtCounterparty(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingDebtAmount:
+			err := h.handleAwaitingDebtAmount(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingDebtDueDate:
+			err := h.handleAwaitingDebtDueDate(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingDebtRepayment:
+			err := h.handleAwaitingDebtRepayment(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingDigestTime:
+			err := h.handleAwaitingDigestTime(m, session)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingTimezone:
+			err := h.handleAwaitingTimezone(m)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingReminderTime:
+			err := h.handleAwaitingReminderTime(m)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingQuickAmount:
+			err := h.handleAwaitingQuickAmount(m, session, log)
+			if err != nil {
+				return err
+			}
+			return nil
+		case model.StateAwaitingTemplateName:
+			err := h.handleAwaitingTemplateName(m, session)
 			if err != nil {
 				return err
 			}
@@ -54,31 +160,96 @@ func (h *messageHandler) handleOnText(m *telebot.Message) error {
 			}
 			return nil
 		default:
-			if _, err := h.b.Send(m.Sender, "Извините, я не понимаю эту команду."); err != nil {
+			if err := sendText(h.b, m.Sender, "Извините, я не понимаю эту команду.", nil); err != nil {
 				return err
 			}
 			return nil
 		}
 	}
 
-	_, err := h.b.Send(m.Sender, "Извините, я не понимаю эту команду. Введите /help для списка команд.")
+	// the text of a receipt QR code read by another app
+	if strings.HasPrefix(strings.TrimSpace(m.Text), "t=") {
+		return h.handleReceipt(m, m.Text)
+	}
+
+	err := sendText(h.b, m.Sender, "Извините, я не понимаю эту команду. Введите /help для списка команд.", nil)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (h *messageHandler) handleStart(m *telebot.Message) error {
+// handlePhoto looks for a receipt QR code on the photo.
+func (h *messageHandler) handlePhoto(m *telebot.Message) error {
+	file, err := h.b.File(&m.Photo.File)
+	if err != nil {
+		return fmt.Errorf("error downloading photo: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("error decoding photo: %w", err)
+	}
+	text, err := decodeQRCode(img)
+	if errors.Is(err, errNoQRCode) {
+		err := sendText(h.b, m.Sender,
+			"QR-код не найден. Сфотографируйте чек так, чтобы код был в кадре целиком и резким.", nil)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("error decoding QR code: %w", err)
+	}
+	return h.handleReceipt(m, text)
+}
+
+// handleReceipt pre-fills a transaction from the text of a receipt QR code and asks for its category.
+func (h *messageHandler) handleReceipt(m *telebot.Message, text string) error {
+	user, err := h.storageInstance.GetUserByChatID(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+	receipt, err := model.ParseReceipt(text, user.Location())
+	if errors.Is(err, model.ErrReceiptInvalid) {
+		err := sendText(h.b, m.Sender, "Это не QR-код кассового чека.", nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	categories, err := h.storageInstance.GetCategoriesByKind(m.Chat.ID, receipt.TransactionType())
+	if err != nil {
+		return err
+	}
+	response, markup := receiptPicker(categories, receipt)
+	err = sendText(h.b, m.Sender, response, markup)
+	return err
+}
+
+// expectsNumber reports whether the session waits for an amount, so that a number is not taken
+// for a new transaction.
+func expectsNumber(state model.UserState) bool {
+	switch state {
+	case model.StateAwaitingAccountBalance, model.StateAwaitingGoalTarget,
+		model.StateAwaitingDebtAmount, model.StateAwaitingDebtRepayment, model.StateAwaitingQuickAmount:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *messageHandler) handleStart(m *telebot.Message, log *logrus.Entry) error {
 	u, err := h.storageInstance.GetUserByChatID(m.Chat.ID)
 	if err != nil {
-		_, err := h.b.Send(m.Sender, "Ошибка при проверке существования пользователя:", err)
+		err := sendText(h.b, m.Sender, "Ошибка при проверке существования пользователя: "+err.Error(), nil)
 		if err != nil {
 			return err
 		}
 	}
 
 	if !u.IsEmpty() {
-		h.log.WithField("chat_id", m.Chat.ID).Warn("found the same user")
+		log.Warn("found the same user")
 		return nil
 	}
 
@@ -90,7 +261,7 @@ func (h *messageHandler) handleStart(m *telebot.Message) error {
 	}
 
 	if err := h.storageInstance.AddUser(user); err != nil {
-		_, err := h.b.Send(m.Sender, "Ошибка при добавлении пользователя:", err)
+		err := sendText(h.b, m.Sender, "Ошибка при добавлении пользователя: "+err.Error(), nil)
 		if err != nil {
 			return err
 		}
@@ -101,14 +272,25 @@ func (h *messageHandler) handleStart(m *telebot.Message) error {
 		ChatID: m.Chat.ID,
 	}
 	if err := h.storageInstance.AddCategory(defaultCategory); err != nil {
-		_, err := h.b.Send(m.Sender, "Ошибка при добавлении общей категории:", err)
+		err := sendText(h.b, m.Sender, "Ошибка при добавлении общей категории: "+err.Error(), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	defaultAccount := model.Account{
+		Name:   "Основной",
+		ChatID: m.Chat.ID,
+	}
+	if err := h.storageInstance.AddAccount(defaultAccount); err != nil {
+		err := sendText(h.b, m.Sender, "Ошибка при добавлении основного счёта: "+err.Error(), nil)
 		if err != nil {
 			return err
 		}
 	}
 
 	welcomeText := "Привет! Нажмите /help для подробной информации"
-	_, err = h.b.Send(m.Sender, welcomeText)
+	err = sendText(h.b, m.Sender, welcomeText, nil)
 	if err != nil {
 		return err
 	}
@@ -119,23 +301,111 @@ func (h *messageHandler) handleHelp(m *telebot.Message) error {
 	helpMessage := "Команды бота:\n" +
 		"/start - начать работу с ботом\n" +
 		"/add_category - добавить новую категорию\n" +
-		"/show_categories - показать все категории\n" +
+		"/show_categories - показать и настроить категории\n" +
+		"/add_account - добавить счёт\n" +
+		"/balance - показать баланс по счетам\n" +
+		"/goal - создать цель накоплений\n" +
+		"/goals - показать прогресс по целям\n" +
+		"/debt - записать долг\n" +
+		"/debts - показать долги и записать погашение\n" +
 		"/stats - показать статистику\n" +
+		"/digest - настроить регулярную сводку\n" +
+		"/reminder - настроить ежедневное напоминание\n" +
+		"/timezone - указать часовой пояс\n" +
+		"/undo - отменить последнее действие\n" +
+		"/history - история изменений\n" +
+		"/dashboard - открыть панель с историей и графиками\n" +
+		"/api_token - получить токен доступа к API\n" +
+		"/templates - шаблоны транзакций\n" +
+		"/keyboard - показать клавиатуру быстрого ввода\n" +
+		"/reset - удалить все транзакции\n" +
+		"/delete_me - удалить все свои данные\n" +
 		"/help - показать эту справку\n" +
 		"...\n" +
-		"Для добавления транзакции просто введите сумму."
+		"Для добавления транзакции просто введите сумму или отправьте фото QR-кода с чека.\n" +
+		"В любом чате можно написать @" + h.b.Me.Username + " 350 кофе, чтобы записать расход, " +
+		"или @" + h.b.Me.Username + " stats, чтобы поделиться статистикой за месяц."
 
-	_, err := h.b.Send(m.Sender, helpMessage)
+	err := sendText(h.b, m.Sender, helpMessage, nil)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (h *messageHandler) handleShowCategories(m *telebot.Message) error {
+// handleDashboard offers the button that opens the Mini App dashboard.
+func (h *messageHandler) handleDashboard(m *telebot.Message, url string) error {
+	if url == "" {
+		err := sendText(h.b, m.Sender, "Панель не настроена на этом сервере.", nil)
+		return err
+	}
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.WebApp("📈 Открыть панель", &telebot.WebApp{URL: url})))
+	err := sendText(h.b, m.Sender, "Транзакции, фильтры и графики — в панели:", markup)
+	return err
+}
+
+// handleAPIToken issues a new personal access token of the REST API, replacing the previous one, and shows
+// it once: only its hash is stored. "/api_token revoke" revokes the token.
+func (h *messageHandler) handleAPIToken(m *telebot.Message, enabled bool) error {
+	if !enabled {
+		err := sendText(h.b, m.Sender, "API не настроен на этом сервере.", nil)
+		return err
+	}
+
+	if strings.TrimSpace(m.Payload) == "revoke" {
+		deleted, err := h.storageInstance.DeleteAPIToken(m.Sender.ID)
+		if err != nil {
+			sendErr := sendText(h.b, m.Sender, "Ошибка при отзыве токена", nil)
+			if sendErr != nil {
+				return fmt.Errorf("%v: %w", err, sendErr)
+			}
+			return err
+		}
+		text := "Токена доступа к API нет."
+		if deleted {
+			text = "Токен доступа к API отозван."
+		}
+		err = sendText(h.b, m.Sender, text, nil)
+		return err
+	}
+
+	token, hash, err := api.NewToken()
+	if err == nil {
+		err = h.storageInstance.SetAPIToken(m.Sender.ID, hash)
+	}
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при создании токена", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	msg := render.New()
+	msg.Text("🔑 ").Bold("Токен доступа к API").Line().Line()
+	msg.Code(token).Line().Line()
+	msg.Text("Передавайте его в заголовке ").Code("Authorization: Bearer <токен>").
+		Text(". Описание API — в ").Code(api.Prefix + "/openapi.yaml").Text(".").Line()
+	msg.Text("Токен показан один раз. Прежний токен больше не действует, отозвать новый можно командой ").
+		Code("/api_token revoke").Text(".")
+	return sendRendered(h.b, m.Sender, msg, nil)
+}
+
+func (h *messageHandler) handleDeleteMe(m *telebot.Message) error {
+	err := sendText(h.b, m.Sender, deleteMeText, deleteMeMarkup())
+	return err
+}
+
+func (h *messageHandler) handleReset(m *telebot.Message) error {
+	err := sendText(h.b, m.Sender, resetText, resetMarkup())
+	return err
+}
+
+func (h *messageHandler) handleShowCategories(m *telebot.Message, log *logrus.Entry) error {
 	categories, err := h.storageInstance.GetCategoriesByChatID(m.Chat.ID)
 	if err != nil {
-		_, sendErr := h.b.Send(m.Sender, fmt.Sprintf("Ошибка при получении категорий: %v", err))
+		sendErr := sendText(h.b, m.Sender, fmt.Sprintf("Ошибка при получении категорий: %v", err), nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
@@ -143,22 +413,15 @@ func (h *messageHandler) handleShowCategories(m *telebot.Message) error {
 	}
 
 	if len(categories) == 0 {
-		h.log.Info("no categories found")
-		if _, err := h.b.Send(m.Sender, "Категории отсутствуют."); err != nil {
+		log.Info("no categories found")
+		if err := sendText(h.b, m.Sender, "Категории отсутствуют.", nil); err != nil {
 			return err
 		}
+		return nil
 	}
 
-	markup := &telebot.ReplyMarkup{}
-	var rows []telebot.Row
-	for _, category := range categories {
-		btnCategory := markup.Text(category.Name)
-		btnRename := markup.Data("Переименовать", "rename:"+strconv.Itoa(int(category.ID)))
-		rows = append(rows, markup.Row(btnCategory), markup.Row(btnRename))
-	}
-
-	markup.Inline(rows...)
-	_, err = h.b.Send(m.Sender, "Категории:", markup)
+	text, markup := categoryListPage(categories, 0, "")
+	err = sendText(h.b, m.Sender, text, markup)
 	if err != nil {
 		return err
 	}
@@ -170,8 +433,9 @@ func (h *messageHandler) handleIncomeExpenseButtons(m *telebot.Message) error {
 	markup := &telebot.ReplyMarkup{}
 	btnIncome := markup.Data("Доход", strconv.Itoa(int(model.TransactionTypeIncome))+":"+m.Text)
 	btnExpense := markup.Data("Расход", strconv.Itoa(int(model.TransactionTypeExpense))+":"+m.Text)
-	markup.Inline(markup.Row(btnIncome, btnExpense))
-	_, err := h.b.Send(m.Sender, "Выберите тип транзакции:", markup)
+	btnTransfer := markup.Data("Перевод", "trfrom:"+m.Text)
+	markup.Inline(markup.Row(btnIncome, btnExpense), markup.Row(btnTransfer))
+	err := sendText(h.b, m.Sender, "Выберите тип транзакции:", markup)
 	if err != nil {
 		return err
 	}
@@ -183,7 +447,7 @@ func (h *messageHandler) handleStatsButtons(m *telebot.Message) error {
 	btnToday := markup.Data("Сегодня", "today")
 	btnPeriod := markup.Data("Период", "period")
 	markup.Inline(markup.Row(btnToday, btnPeriod))
-	_, err := h.b.Send(m.Sender, "Выберите период:", markup)
+	err := sendText(h.b, m.Sender, "Выберите период:", markup)
 	if err != nil {
 		return err
 	}
@@ -191,87 +455,895 @@ func (h *messageHandler) handleStatsButtons(m *telebot.Message) error {
 }
 
 func (h *messageHandler) handleAwaitingRenameCategory(m *telebot.Message, session *model.UserSession) error {
-	err := h.storageInstance.RenameCategory(int64(session.CategoryID), m.Text)
+	name, err := h.checkCategoryName(m.Chat.ID, m.Text, int64(session.CategoryID))
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, nameErrorText(err), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	err = h.storageInstance.RenameCategory(m.Chat.ID, int64(session.CategoryID), name)
+	if errors.Is(err, model.ErrUnknownCategory) {
+		userSessions.Delete(m.Sender.ID)
+		err = sendText(h.b, m.Sender, "Категория не найдена.", nil)
+		return err
+	}
 	if err != nil {
-		_, sendErr := h.b.Send(m.Sender, "Ошибка при переименовании категории: "+err.Error())
+		sendErr := sendText(h.b, m.Sender, nameErrorText(err), nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
+		return err
 	}
 
-	_, err = h.b.Send(m.Sender, "Категория успешно переименована в '"+m.Text+"'")
+	err = sendText(
+		h.b,
+		m.Sender,
+		"Категория успешно переименована в '"+name+"'",
+		undoMarkup(model.ActionRenameCategory, int64(session.CategoryID)),
+	)
 	if err != nil {
 		return err
 	}
 
-	delete(userSessions, m.Sender.ID)
+	userSessions.Delete(m.Sender.ID)
 	return nil
 }
 
-func (h *messageHandler) handleAwaitingNewCategoryName(m *telebot.Message) error {
-	err := h.storageInstance.AddCategory(model.Category{
-		Name:   m.Text,
-		ChatID: m.Chat.ID,
+func (h *messageHandler) handleAwaitingNewCategoryName(m *telebot.Message, session *model.UserSession) error {
+	name, err := h.checkCategoryName(m.Chat.ID, m.Text, 0)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, nameErrorText(err), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	err = h.storageInstance.AddCategory(model.Category{
+		Name:     name,
+		ChatID:   m.Chat.ID,
+		ParentID: session.ParentCategoryID,
 	})
 	if err != nil {
-		_, sendErr := h.b.Send(m.Sender, "Ошибка при добавлении категории: "+err.Error())
+		sendErr := sendText(h.b, m.Sender, nameErrorText(err), nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
+		return err
 	}
 
-	_, err = h.b.Send(m.Sender, "Категория '"+m.Text+"' успешно добавлена.")
+	err = sendText(h.b, m.Sender, "Категория '"+name+"' успешно добавлена.", nil)
 	if err != nil {
 		return err
 	}
 
-	delete(userSessions, m.Sender.ID)
+	userSessions.Delete(m.Sender.ID)
 	return nil
 }
 
-func (h *messageHandler) handlePeriodInput(m *telebot.Message) error {
-	periodParts := strings.Split(m.Text, "-")
-	if len(periodParts) != 2 {
-		_, err := h.b.Send(m.Sender, "Неправильный формат периода. Используйте формат ДД.ММ.ГГГГ-ДД.ММ.ГГГГ.")
-		if err != nil {
-			return err
+func (h *messageHandler) handleAwaitingCategoryIcon(m *telebot.Message, session *model.UserSession) error {
+	icon, err := model.NormalizeCategoryIcon(m.Text)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender,
+			"Иконка должна быть одним эмодзи. Отправьте эмодзи или '-', чтобы убрать иконку:", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
 		}
 		return nil
 	}
 
-	startDate, errStart := time.Parse("02.01.2006", periodParts[0])
-	endDate, errEnd := time.Parse("02.01.2006", periodParts[1])
-	if errStart != nil || errEnd != nil {
-		_, sendErr := h.b.Send(m.Sender, "Ошибка в датах. Используйте формат ДД.ММ.ГГГГ.")
+	err = h.storageInstance.SetCategoryIcon(m.Chat.ID, int64(session.CategoryID), icon)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при сохранении иконки: "+err.Error(), nil)
 		if sendErr != nil {
-			return fmt.Errorf("%v, %v: %w", errStart, errEnd, sendErr)
+			return fmt.Errorf("%v: %w", err, sendErr)
 		}
-		return fmt.Errorf("%v, %v", errStart, errEnd)
+		return err
 	}
 
-	err := h.handleStats(m.Sender, startDate, endDate)
+	err = sendText(h.b, m.Sender, "Иконка категории обновлена.", nil)
 	if err != nil {
 		return err
 	}
-	delete(userSessions, m.Sender.ID)
+
+	userSessions.Delete(m.Sender.ID)
 	return nil
 }
 
-func (h *messageHandler) handleStats(sender *telebot.User, startDate, endDate time.Time) error {
-	incomeCategories, expenseCategories, err := h.storageInstance.GetTransactionsStatsByCategory(sender.ID, startDate, endDate)
+// handleSearchQuery sends the first page of the listing the search was started from, filtered by the prefix.
+func (h *messageHandler) handleSearchQuery(m *telebot.Message, session *model.UserSession) error {
+	userSessions.Delete(m.Sender.ID)
+
+	msg, markup, err := renderListing(h.storageInstance, m.Chat.ID, session.SearchRoute, 0, normalizeSearchQuery(m.Text))
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при поиске: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+	return sendRendered(h.b, m.Sender, msg, markup)
+}
+
+func (h *messageHandler) handleAwaitingAccountName(m *telebot.Message, session *model.UserSession) error {
+	name, err := model.NormalizeName(m.Text)
+	if err == nil {
+		var accounts []model.Account
+		accounts, err = h.storageInstance.GetAccountsByChatID(m.Chat.ID)
+		for _, account := range accounts {
+			if strings.EqualFold(account.Name, name) {
+				err = storage.ErrAccountExists
+			}
+		}
+	}
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, nameErrorText(err), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	session.AccountName = name
+	session.State = model.StateAwaitingAccountBalance
+	err = sendText(h.b, m.Sender, "Введите начальный баланс счёта:", nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h *messageHandler) handleAwaitingAccountBalance(m *telebot.Message, session *model.UserSession) error {
+	balance, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(m.Text), ",", "."), 64)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender,
+			"Неправильный формат суммы. Введите начальный баланс, например 1500.50:", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	err = h.storageInstance.AddAccount(model.Account{
+		ChatID:         m.Chat.ID,
+		Name:           session.AccountName,
+		OpeningBalance: balance,
+	})
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, nameErrorText(err), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	err = sendText(h.b, m.Sender, "Счёт '"+session.AccountName+"' успешно добавлен.", nil)
+	if err != nil {
+		return err
+	}
+
+	userSessions.Delete(m.Sender.ID)
+	return nil
+}
+
+func (h *messageHandler) handleBalance(m *telebot.Message) error {
+	balances, err := h.storageInstance.GetAccountBalances(m.Chat.ID)
 	if err != nil {
-		_, sendErr := h.b.Send(sender, "Ошибка при получении статистики: "+err.Error())
+		sendErr := sendText(h.b, m.Sender, "Ошибка при получении баланса: "+err.Error(), nil)
 		if sendErr != nil {
 			return fmt.Errorf("%v: %w", err, sendErr)
 		}
 		return err
 	}
 
-	response := getStats(incomeCategories, expenseCategories)
+	if len(balances) == 0 {
+		err := sendText(h.b, m.Sender, "Счета отсутствуют. Добавьте счёт командой /add_account.", nil)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	return sendRendered(h.b, m.Sender, formatBalances(balances), nil)
+}
+
+func (h *messageHandler) handleAwaitingGoalName(m *telebot.Message, session *model.UserSession) error {
+	name, err := model.NormalizeName(m.Text)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, nameErrorText(err), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	session.GoalName = name
+	session.State = model.StateAwaitingGoalTarget
+	err = sendText(h.b, m.Sender, "Введите сумму, которую хотите накопить:", nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h *messageHandler) handleAwaitingGoalTarget(m *telebot.Message, session *model.UserSession) error {
+	target, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(m.Text), ",", "."), 64)
+	if err != nil || target <= 0 {
+		sendErr := sendText(h.b, m.Sender, "Сумма должна быть положительным числом. Введите сумму цели:", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
 
-	_, err = h.b.Send(sender, response, &telebot.SendOptions{ParseMode: telebot.ModeMarkdown})
+	session.GoalTarget = target
+	session.State = model.StateAwaitingGoalDeadline
+	err = sendText(h.b, m.Sender, "Введите срок в формате ДД.ММ.ГГГГ:", nil)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (h *messageHandler) handleAwaitingGoalDeadline(m *telebot.Message, session *model.UserSession) error {
+	deadline, err := time.Parse("02.01.2006", strings.TrimSpace(m.Text))
+	if err != nil || !deadline.After(time.Now()) {
+		sendErr := sendText(h.b, m.Sender, "Срок должен быть датой в будущем в формате ДД.ММ.ГГГГ:", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	goal := model.Goal{
+		ChatID:       m.Chat.ID,
+		Name:         session.GoalName,
+		TargetAmount: session.GoalTarget,
+		Deadline:     deadline,
+		CreatedAt:    time.Now(),
+	}
+	if err := h.storageInstance.AddGoal(goal); err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при создании цели: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	err = sendText(
+		h.b,
+		m.Sender,
+		"Цель создана. Чтобы пополнить её, нажмите «🎯 В цель» после добавления "+
+			"транзакции или перевода.\n\n"+formatGoal(goal, time.Now()),
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	userSessions.Delete(m.Sender.ID)
+	return nil
+}
+
+func (h *messageHandler) handleGoals(m *telebot.Message) error {
+	goals, err := h.storageInstance.GetGoalsByChatID(m.Chat.ID)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при получении целей: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	if len(goals) == 0 {
+		err := sendText(h.b, m.Sender, "Целей пока нет. Создайте цель командой /goal.", nil)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	return sendRendered(h.b, m.Sender, formatGoals(goals, time.Now()), nil)
+}
+
+func (h *messageHandler) handleDebt(m *telebot.Message) error {
+	markup := &telebot.ReplyMarkup{}
+	btnLent := markup.Data("Я дал в долг", "debt_new:"+strconv.Itoa(int(model.DebtDirectionLent)))
+	btnBorrowed := markup.Data("Я взял в долг", "debt_new:"+strconv.Itoa(int(model.DebtDirectionBorrowed)))
+	markup.Inline(markup.Row(btnLent, btnBorrowed))
+	err := sendText(h.b, m.Sender, "Выберите тип долга:", markup)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h *messageHandler) handleDebts(m *telebot.Message) error {
+	msg, markup, err := renderListing(h.storageInstance, m.Chat.ID, []string{"debts"}, 0, "")
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при получении долгов: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+	return sendRendered(h.b, m.Sender, msg, markup)
+}
+
+func (h *messageHandler) handleAwaitingDebtCounterparty(m *telebot.Message, session *model.UserSession) error {
+	name, err := model.NormalizeName(m.Text)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, nameErrorText(err), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	session.Debt.Counterparty = name
+	session.State = model.StateAwaitingDebtAmount
+	err = sendText(h.b, m.Sender, "Введите сумму долга:", nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h *messageHandler) handleAwaitingDebtAmount(m *telebot.Message, session *model.UserSession) error {
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(m.Text), ",", "."), 64)
+	if err != nil || amount <= 0 {
+		sendErr := sendText(h.b, m.Sender, "Сумма должна быть положительным числом. Введите сумму долга:", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	session.Debt.Amount = amount
+	session.State = model.StateAwaitingDebtDueDate
+	err = sendText(h.b, m.Sender, "Введите срок возврата в формате ДД.ММ.ГГГГ или '-', если срока нет:", nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h *messageHandler) handleAwaitingDebtDueDate(m *telebot.Message, session *model.UserSession) error {
+	text := strings.TrimSpace(m.Text)
+	if text != "-" {
+		dueDate, err := time.Parse("02.01.2006", text)
+		if err != nil {
+			sendErr := sendText(h.b, m.Sender, "Неправильный формат даты. Используйте ДД.ММ.ГГГГ или '-':", nil)
+			if sendErr != nil {
+				return fmt.Errorf("%v: %w", err, sendErr)
+			}
+			return nil
+		}
+		session.Debt.DueDate = dueDate
+	}
+
+	session.Debt.ChatID = m.Chat.ID
+	if err := h.storageInstance.AddDebt(session.Debt); err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при сохранении долга: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	err := sendText(h.b, m.Sender, "Долг записан: "+debtLabel(session.Debt), nil)
+	if err != nil {
+		return err
+	}
+
+	userSessions.Delete(m.Sender.ID)
+	return nil
+}
+
+func (h *messageHandler) handleAwaitingDebtRepayment(m *telebot.Message, session *model.UserSession) error {
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(m.Text), ",", "."), 64)
+	if err != nil || amount <= 0 {
+		sendErr := sendText(h.b, m.Sender, "Сумма должна быть положительным числом. Введите сумму погашения:", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	err = h.storageInstance.AddDebtRepayment(m.Chat.ID, session.Debt.ID, amount)
+	if errors.Is(err, storage.ErrRepaymentTooLarge) {
+		err := sendText(
+			h.b,
+			m.Sender,
+			fmt.Sprintf("Сумма больше остатка долга %.2f. Введите сумму погашения:",
+				session.Debt.Remaining()),
+			nil,
+		)
+		return err
+	}
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при сохранении погашения: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	session.Debt.Repaid += amount
+	text := "Погашение записано. Остаток: " + debtLabel(session.Debt)
+	if session.Debt.Remaining() <= 0 {
+		text = "Долг погашен полностью. 🎉"
+	}
+	err = sendText(h.b, m.Sender, text, nil)
+	if err != nil {
+		return err
+	}
+
+	userSessions.Delete(m.Sender.ID)
+	return nil
+}
+
+func (h *messageHandler) handleDigest(m *telebot.Message) error {
+	settings, err := h.storageInstance.GetDigestSettingsByChatID(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	text := "Регулярная сводка выключена."
+	if settings.Period != model.DigestPeriodOff {
+		text = fmt.Sprintf("Сводка приходит %s в %02d:%02d.",
+			digestPeriodText(settings.Period), settings.SendMinute/60, settings.SendMinute%60)
+	}
+	text += "\nЧасовой пояс: " + model.LoadLocation(settings.Timezone).String() +
+		" (изменить: /timezone).\n\nКак часто присылать сводку?"
+
+	err = sendText(h.b, m.Sender, text, digestMarkup())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h *messageHandler) handleAwaitingDigestTime(m *telebot.Message, session *model.UserSession) error {
+	sendMinute, err := parseSendTime(m.Text)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender,
+			"Неправильный формат времени. Введите время в формате ЧЧ:ММ, например 09:00:", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	user, err := h.storageInstance.GetUserByChatID(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	settings := model.DigestSettings{
+		ChatID:     m.Chat.ID,
+		Period:     session.DigestPeriod,
+		SendMinute: sendMinute,
+		Timezone:   user.Timezone,
+	}
+	// Start from the latest scheduled moment so that the first digest comes at the next one.
+	settings.LastSentAt = settings.LastDue(time.Now())
+	if err := h.storageInstance.SetDigestSettings(settings); err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при сохранении настроек сводки: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	next := settings.NextDue(settings.LastSentAt)
+	err = sendText(h.b, m.Sender, "Сводка включена. Следующая придёт "+next.Format("02.01.2006 в 15:04")+".", nil)
+	if err != nil {
+		return err
+	}
+
+	userSessions.Delete(m.Sender.ID)
+	return nil
+}
+
+func (h *messageHandler) handleAwaitingTimezone(m *telebot.Message) error {
+	timezone, err := parseTimezone(m.Text)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender,
+			"Не удалось распознать часовой пояс. Введите, например, Europe/Moscow или +3:", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	if err := h.storageInstance.SetUserTimezone(m.Chat.ID, timezone); err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при сохранении часового пояса: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	err = sendText(h.b, m.Sender, "Часовой пояс сохранён: "+timezone+".", nil)
+	if err != nil {
+		return err
+	}
+
+	userSessions.Delete(m.Sender.ID)
+	return nil
+}
+
+func (h *messageHandler) handleReminder(m *telebot.Message) error {
+	settings, enabled, err := h.storageInstance.GetReminderSettingsByChatID(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	text := "Напоминание выключено."
+	if enabled {
+		text = "Напоминание приходит в " + formatReminderTime(settings.SendMinute) +
+			", если за день не добавлено ни одной записи."
+	}
+	text += "\n\nВведите новое время в формате ЧЧ:ММ или '-', чтобы выключить напоминание:"
+
+	userSessions.Set(m.Sender.ID, &model.UserSession{State: model.StateAwaitingReminderTime})
+	err = sendText(h.b, m.Sender, text, nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h *messageHandler) handleAwaitingReminderTime(m *telebot.Message) error {
+	if strings.TrimSpace(m.Text) == "-" {
+		if err := h.storageInstance.DeleteReminder(m.Chat.ID); err != nil {
+			return err
+		}
+		userSessions.Delete(m.Sender.ID)
+		err := sendText(h.b, m.Sender, "Напоминание выключено.", nil)
+		return err
+	}
+
+	sendMinute, err := parseSendTime(m.Text)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Неправильный формат времени. Введите время в формате ЧЧ:ММ или '-':", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+
+	if err := h.storageInstance.SetReminder(m.Chat.ID, sendMinute); err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при сохранении напоминания: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	err = sendText(
+		h.b,
+		m.Sender,
+		"Напоминание включено на "+formatReminderTime(sendMinute)+
+			". Часовой пояс можно изменить командой /timezone.",
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	userSessions.Delete(m.Sender.ID)
+	return nil
+}
+
+// handleAwaitingQuickAmount books the amount to the category chosen on the reminder keyboard. Users with
+// several accounts get the account picker first.
+func (h *messageHandler) handleAwaitingQuickAmount(
+	m *telebot.Message,
+	session *model.UserSession,
+	log *logrus.Entry,
+) error {
+	amountText := strings.ReplaceAll(strings.TrimSpace(m.Text), ",", ".")
+	amount, err := strconv.ParseFloat(amountText, 64)
+	if err != nil || amount <= 0 {
+		sendErr := sendText(h.b, m.Sender, "Сумма должна быть положительным числом. Введите сумму:", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return nil
+	}
+	userSessions.Delete(m.Sender.ID)
+
+	category, err := h.storageInstance.GetCategoryByID(m.Chat.ID, int64(session.CategoryID))
+	if err != nil {
+		return err
+	}
+	transactionType := model.TransactionTypeExpense
+	if category.Kind == model.CategoryKindIncome {
+		transactionType = model.TransactionTypeIncome
+	}
+
+	accounts, err := h.storageInstance.GetAccountsByChatID(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+	if len(accounts) != 1 {
+		route := []string{"accpick", strconv.FormatInt(category.ID, 10), strconv.Itoa(int(transactionType)), amountText}
+		msg, markup, err := renderListing(h.storageInstance, m.Chat.ID, route, 0, "")
+		if err != nil {
+			return err
+		}
+		return sendRendered(h.b, m.Sender, msg, markup)
+	}
+
+	transactionId, err := handleTransaction(
+		h.storageInstance, m.Chat.ID, accounts[0].ID, category.ID, amount, transactionType, time.Time{},
+	)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, transactionErrorText(err), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	text := fmt.Sprintf("Транзакция на сумму %s добавлена в категорию %s", amountText, category.Label())
+	err = sendText(h.b, m.Sender, text, transactionMarkup(h.storageInstance, log, m.Chat.ID, transactionId, true))
+	return err
+}
+
+// handleQuickButton handles a tap on the quick-add keyboard and reports whether the text was a button.
+// A tap interrupts any dialog in progress.
+func (h *messageHandler) handleQuickButton(m *telebot.Message, log *logrus.Entry) (bool, error) {
+	switch {
+	case m.Text == repeatButtonText:
+		userSessions.Delete(m.Sender.ID)
+		return true, h.handleRepeatLast(m, log)
+	case strings.HasPrefix(m.Text, templateButtonPrefix):
+		templates, err := h.storageInstance.GetTemplatesByChatID(m.Chat.ID)
+		if err != nil {
+			return true, err
+		}
+		template, ok := findTemplate(templates, m.Text)
+		if !ok {
+			return false, nil
+		}
+		userSessions.Delete(m.Sender.ID)
+		transactionId, text, err := bookTemplate(h.storageInstance, template)
+		if err != nil {
+			sendErr := sendText(h.b, m.Sender, transactionErrorText(err), nil)
+			if sendErr != nil {
+				return true, fmt.Errorf("%v: %w", err, sendErr)
+			}
+			return true, err
+		}
+		err = sendText(h.b, m.Sender, text, transactionMarkup(h.storageInstance, log, m.Chat.ID, transactionId, false))
+		return true, err
+	case strings.HasPrefix(m.Text, categoryButtonPrefix):
+		// The button is looked up among the categories the keyboard is built from first. The keyboard stays
+		// on screen while they change, so a button of an older keyboard is looked up among all categories.
+		category, ok, err := h.findCategoryButton(m.Chat.ID, m.Text)
+		if err != nil || !ok {
+			return ok, err
+		}
+		userSessions.Set(m.Sender.ID, &model.UserSession{
+			State:      model.StateAwaitingQuickAmount,
+			CategoryID: int(category.ID),
+		})
+		err = sendText(h.b, m.Sender, "Введите сумму для категории "+category.Label()+":", nil)
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+// findCategoryButton returns the category of a quick-add keyboard button.
+func (h *messageHandler) findCategoryButton(chatID int64, text string) (model.Category, bool, error) {
+	categories, err := h.storageInstance.GetMostUsedCategories(chatID, quickKeyboardCategories)
+	if err != nil {
+		return model.Category{}, false, err
+	}
+	if category, ok := findCategory(categories, text); ok {
+		return category, true, nil
+	}
+	categories, err = h.storageInstance.GetCategoriesByChatID(chatID)
+	if err != nil {
+		return model.Category{}, false, err
+	}
+	category, ok := findCategory(categories, text)
+	return category, ok, nil
+}
+
+// handleRepeatLast books the last income or expense again, now.
+func (h *messageHandler) handleRepeatLast(m *telebot.Message, log *logrus.Entry) error {
+	last, err := h.storageInstance.GetLastTransaction(m.Chat.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err := sendText(h.b, m.Sender, "Транзакций пока нет.", nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	transactionId, err := handleTransaction(
+		h.storageInstance, m.Chat.ID, last.AccountID, last.CategoryID, last.Amount, last.TransactionType, time.Time{},
+	)
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, transactionErrorText(err), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+	text := fmt.Sprintf("Транзакция на сумму %s добавлена повторно", strconv.FormatFloat(last.Amount, 'f', -1, 64))
+	err = sendText(h.b, m.Sender, text, transactionMarkup(h.storageInstance, log, m.Chat.ID, transactionId, true))
+	return err
+}
+
+// handleHistory shows the latest changes of the user, or of a transaction given its number after the command.
+// The history of a category is opened from its menu.
+func (h *messageHandler) handleHistory(m *telebot.Message) error {
+	entity, entityID := "", int64(0)
+	if payload := strings.TrimPrefix(strings.TrimSpace(m.Payload), "#"); payload != "" {
+		id, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			err := sendText(h.b, m.Sender, "Использование: /history или /history <номер транзакции>", nil)
+			return err
+		}
+		entity, entityID = model.AuditEntityTransaction, id
+	}
+
+	history, markup, err := buildHistory(h.storageInstance, m.Chat.ID, entity, entityID, 0)
+	if err != nil {
+		return err
+	}
+	return sendRendered(h.b, m.Sender, history, markup)
+}
+
+// handleUndo reverts the last action of the user made within undoWindow.
+func (h *messageHandler) handleUndo(m *telebot.Message) error {
+	action, err := h.storageInstance.UndoLastAction(m.Chat.ID, undoWindow, 0, 0)
+	sendErr := sendText(h.b, m.Sender, undoText(action, err), nil)
+	if err != nil && !isUndoFailure(err) {
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+	return sendErr
+}
+
+func (h *messageHandler) handleTemplates(m *telebot.Message) error {
+	templates, err := h.storageInstance.GetTemplatesByChatID(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+	text, markup := templateList(templates)
+	err = sendText(h.b, m.Sender, text, markup)
+	return err
+}
+
+// handleKeyboard shows the quick-add keyboard, refreshed with the current top categories and templates.
+func (h *messageHandler) handleKeyboard(m *telebot.Message) error {
+	keyboard, err := loadQuickKeyboard(h.storageInstance, m.Chat.ID)
+	if err != nil {
+		return err
+	}
+	err = sendText(h.b, m.Sender, "Клавиатура быстрого ввода обновлена.", keyboard)
+	return err
+}
+
+// handleAwaitingTemplateName saves the transaction of the session as a template under the name.
+func (h *messageHandler) handleAwaitingTemplateName(m *telebot.Message, session *model.UserSession) error {
+	name, err := model.NormalizeName(m.Text)
+	if err != nil {
+		err := sendText(h.b, m.Sender, nameErrorText(err), nil)
+		return err
+	}
+
+	templates, err := h.storageInstance.GetTemplatesByChatID(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+	if len(templates) >= maxTemplates {
+		userSessions.Delete(m.Sender.ID)
+		err := sendText(
+			h.b,
+			m.Sender,
+			fmt.Sprintf("Можно сохранить не больше %d шаблонов. Удалите ненужные в /templates.",
+				maxTemplates),
+			nil,
+		)
+		return err
+	}
+
+	err = h.storageInstance.AddTemplateFromTransaction(m.Chat.ID, session.TransactionID, name)
+	if errors.Is(err, storage.ErrTemplateExists) {
+		err := sendText(h.b, m.Sender, nameErrorText(err), nil)
+		return err
+	}
+	userSessions.Delete(m.Sender.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err := sendText(h.b, m.Sender, "Транзакция не найдена.", nil)
+		return err
+	}
+	if err != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка при сохранении шаблона", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	keyboard, err := loadQuickKeyboard(h.storageInstance, m.Chat.ID)
+	if err != nil {
+		return err
+	}
+	err = sendText(h.b, m.Sender, "Шаблон «"+name+"» сохранён и добавлен на клавиатуру.", keyboard)
+	return err
+}
+
+// checkCategoryName normalizes the name and makes sure no other category of the chat has it, ignoring case.
+// categoryID is the category being renamed, 0 for a new category.
+func (h *messageHandler) checkCategoryName(chatID int64, text string, categoryID int64) (string, error) {
+	name, err := model.NormalizeCategoryName(text)
+	if err != nil {
+		return "", err
+	}
+
+	categories, err := h.storageInstance.GetCategoriesByChatID(chatID)
+	if err != nil {
+		return "", err
+	}
+	for _, category := range categories {
+		if category.ID != categoryID && strings.EqualFold(category.Name, name) {
+			return "", storage.ErrCategoryExists
+		}
+	}
+	return name, nil
+}
+
+func (h *messageHandler) handlePeriodInput(m *telebot.Message) error {
+	periodParts := strings.Split(m.Text, "-")
+	if len(periodParts) != 2 {
+		err := sendText(h.b, m.Sender, "Неправильный формат периода. Используйте формат ДД.ММ.ГГГГ-ДД.ММ.ГГГГ.", nil)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	startDate, errStart := time.Parse("02.01.2006", periodParts[0])
+	endDate, errEnd := time.Parse("02.01.2006", periodParts[1])
+	if errStart != nil || errEnd != nil {
+		sendErr := sendText(h.b, m.Sender, "Ошибка в датах. Используйте формат ДД.ММ.ГГГГ.", nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v, %v: %w", errStart, errEnd, sendErr)
+		}
+		return fmt.Errorf("%v, %v", errStart, errEnd)
+	}
+
+	err := h.handleStats(m.Sender, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	userSessions.Delete(m.Sender.ID)
+	return nil
+}
+
+func (h *messageHandler) handleStats(sender *telebot.User, startDate, endDate time.Time) error {
+	response, markup, err := buildStats(h.storageInstance, sender.ID, startDate, endDate, false, h.statsTop)
+	if err != nil {
+		sendErr := sendText(h.b, sender, "Ошибка при получении статистики: "+err.Error(), nil)
+		if sendErr != nil {
+			return fmt.Errorf("%v: %w", err, sendErr)
+		}
+		return err
+	}
+
+	return sendRendered(h.b, sender, response, markup)
+}
@@ -0,0 +1,178 @@
+package bot
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/logger"
+	"github.com/cupitman9/budget-bot/internal/metrics"
+)
+
+// requestLogKey is the telebot.Context key of the request-scoped log entry.
+const requestLogKey = "log"
+
+// withRequestLog attaches a log entry with the update ID, chat ID and command or callback action to the
+// context and logs the duration of the update once it is handled. The text of the message is a sensitive
+// field, see logger.FieldText.
+func withRequestLog(log *logrus.Logger) telebot.MiddlewareFunc {
+	return func(next telebot.HandlerFunc) telebot.HandlerFunc {
+		return func(ctx telebot.Context) error {
+			fields := logrus.Fields{"update_id": ctx.Update().ID}
+			if chat := ctx.Chat(); chat != nil {
+				fields["chat_id"] = chat.ID
+			}
+			if sender := ctx.Sender(); sender != nil {
+				fields["user_id"] = sender.ID
+			}
+			if c := ctx.Callback(); c != nil {
+				fields["action"] = callbackAction(c)
+			} else if q := ctx.Query(); q != nil {
+				fields[logger.FieldText] = q.Text
+			} else if m := ctx.Message(); m != nil {
+				if command := messageCommand(m); command != "" {
+					fields["command"] = command
+				} else {
+					fields[logger.FieldText] = m.Text
+				}
+			}
+			entry := log.WithFields(fields)
+			ctx.Set(requestLogKey, entry)
+
+			start := time.Now()
+			err := next(ctx)
+			entry.WithField("duration", time.Since(start).String()).Info("update handled")
+			return err
+		}
+	}
+}
+
+// requestLog returns the request-scoped log entry set by withRequestLog.
+func requestLog(ctx telebot.Context) *logrus.Entry {
+	if entry, ok := ctx.Get(requestLogKey).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// recoverPanic turns a panic in a handler into a logged error with the stack trace and apologizes to the user,
+// instead of letting it crash the bot.
+func recoverPanic() telebot.MiddlewareFunc {
+	return func(next telebot.HandlerFunc) telebot.HandlerFunc {
+		return func(ctx telebot.Context) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				metrics.HandlerPanics.WithLabelValues(updateType(ctx)).Inc()
+				requestLog(ctx).WithField("stack", string(debug.Stack())).Errorf("panic handling update: %v", r)
+				if ctx.Callback() != nil {
+					_ = ctx.Respond()
+				}
+				if isInline(ctx) {
+					return
+				}
+				sendErr := sendText(ctx.Bot(), ctx.Recipient(), "Что-то пошло не так. Попробуйте ещё раз.", nil)
+				if sendErr != nil {
+					err = fmt.Errorf("error sending apology after panic: %w", sendErr)
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}
+
+// rateLimit drops the updates of a chat that exceeds its token bucket, see rateLimiter.
+func rateLimit(limiter *rateLimiter) telebot.MiddlewareFunc {
+	return func(next telebot.HandlerFunc) telebot.HandlerFunc {
+		return func(ctx telebot.Context) error {
+			var chatID int64
+			if chat := ctx.Chat(); chat != nil {
+				chatID = chat.ID
+			} else if sender := ctx.Sender(); sender != nil {
+				chatID = sender.ID
+			}
+
+			allowed, warn := limiter.Allow(chatID, time.Now())
+			if allowed {
+				return next(ctx)
+			}
+			metrics.RateLimited.WithLabelValues(updateType(ctx)).Inc()
+			requestLog(ctx).Warn("update rate limited")
+
+			text := "Слишком много запросов. Подождите немного."
+			if isInline(ctx) {
+				return nil
+			}
+			if ctx.Callback() != nil {
+				return ctx.Respond(&telebot.CallbackResponse{Text: text})
+			}
+			if warn {
+				return sendText(ctx.Bot(), ctx.Recipient(), text, nil)
+			}
+			return nil
+		}
+	}
+}
+
+// observe wraps a handler registered under name: it records the update, the handler duration and errors,
+// and logs the error instead of passing it to telebot. Callbacks are labeled by their action, e.g. "debt".
+func observe(name string) telebot.MiddlewareFunc {
+	return func(next telebot.HandlerFunc) telebot.HandlerFunc {
+		return func(ctx telebot.Context) error {
+			handler := name
+			if ctx.Callback() != nil {
+				handler = callbackAction(ctx.Callback())
+			}
+			metrics.Updates.WithLabelValues(updateType(ctx), handler).Inc()
+
+			start := time.Now()
+			err := next(ctx)
+			metrics.HandlerDuration.WithLabelValues(handler).Observe(time.Since(start).Seconds())
+			if err != nil {
+				metrics.HandlerErrors.WithLabelValues(handler).Inc()
+				requestLog(ctx).WithError(err).Errorf("error handling %s", handler)
+			}
+			return nil
+		}
+	}
+}
+
+func updateType(ctx telebot.Context) string {
+	switch {
+	case ctx.Callback() != nil:
+		return "callback"
+	case ctx.Query() != nil:
+		return "inline_query"
+	case ctx.InlineResult() != nil:
+		return "inline_result"
+	}
+	return "message"
+}
+
+// isInline reports whether the update comes from inline mode. Such updates have no chat to reply to,
+// and a private message would arrive unasked, so middleware drops them silently instead.
+func isInline(ctx telebot.Context) bool {
+	return ctx.Query() != nil || ctx.InlineResult() != nil
+}
+
+// callbackAction returns the prefix of the callback data, the part the callback handler dispatches on.
+func callbackAction(c *telebot.Callback) string {
+	data := strings.ReplaceAll(c.Data, "\f", "")
+	action, _, _ := strings.Cut(data, ":")
+	return action
+}
+
+// messageCommand returns the command of the message without the bot username, or "" for plain text.
+func messageCommand(m *telebot.Message) string {
+	if !strings.HasPrefix(m.Text, "/") {
+		return ""
+	}
+	command, _, _ := strings.Cut(strings.Fields(m.Text)[0], "@")
+	return command
+}
@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/telebot.v3"
+)
+
+// maxCallbackDataLength is the Telegram limit for callback data. telebot adds a leading \f to it.
+const maxCallbackDataLength = 64 - 1
+
+// pageList renders one page of a long list of inline buttons with navigation and search controls.
+//
+// Navigation buttons carry "<route>:<page>:<query>" as callback data, so the route must contain everything
+// the handler needs to rebuild the list, e.g. "subcat:<parent id>:<type>:<amount>".
+type pageList struct {
+	Items    []telebot.Btn
+	Columns  int
+	PageSize int
+	Page     int
+	Route    string
+	Query    string
+	// Searchable adds a search button that asks for a name prefix, see renderListing.
+	Searchable bool
+	// Count is the number of entries of a listing whose page is rendered as text, such as the history, and
+	// that has no Items.
+	Count int
+}
+
+// Rows returns the item rows of the current page followed by the navigation row.
+func (l pageList) Rows(markup *telebot.ReplyMarkup) []telebot.Row {
+	columns := max(l.Columns, 1)
+	pages := l.Pages()
+	page := min(max(l.Page, 0), pages-1)
+
+	end := min((page+1)*l.PageSize, len(l.Items))
+	start := min(page*l.PageSize, end)
+
+	var rows []telebot.Row
+	var row telebot.Row
+	for i, btn := range l.Items[start:end] {
+		row = append(row, btn)
+		if (i+1)%columns == 0 || start+i == end-1 {
+			rows = append(rows, row)
+			row = telebot.Row{}
+		}
+	}
+
+	var nav telebot.Row
+	if page > 0 {
+		nav = append(nav, markup.Data("‹", pageData(l.Route, page-1, l.Query)))
+	}
+	if pages > 1 {
+		nav = append(nav, markup.Data(fmt.Sprintf("%d/%d", page+1, pages), "noop"))
+	}
+	if page < pages-1 {
+		nav = append(nav, markup.Data("›", pageData(l.Route, page+1, l.Query)))
+	}
+	if l.Searchable {
+		if l.Query != "" {
+			nav = append(nav, markup.Data("✕ "+l.Query, pageData(l.Route, 0, "")))
+		} else {
+			nav = append(nav, markup.Data("🔍", "search:"+l.Route))
+		}
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	return rows
+}
+
+// Pages returns the number of pages, at least one.
+func (l pageList) Pages() int {
+	count := max(len(l.Items), l.Count)
+	if l.PageSize <= 0 || count == 0 {
+		return 1
+	}
+	return (count + l.PageSize - 1) / l.PageSize
+}
+
+// pageData builds the callback data opening a page, shortening the query so that it fits the Telegram limit.
+func pageData(route string, page int, query string) string {
+	data := route + ":" + strconv.Itoa(page) + ":"
+	for len(data)+len(query) > maxCallbackDataLength && query != "" {
+		_, size := utf8.DecodeLastRuneInString(query)
+		query = query[:len(query)-size]
+	}
+	return data + query
+}
+
+// parsePageArgs reads the optional "<page>:<query>" tail of page callback data.
+func parsePageArgs(args []string) (int, string) {
+	if len(args) == 0 {
+		return 0, ""
+	}
+	page, err := strconv.Atoi(args[0])
+	if err != nil || page < 0 {
+		page = 0
+	}
+	if len(args) < 2 {
+		return page, ""
+	}
+	return page, strings.Join(args[1:], ":")
+}
+
+// matchesPrefix reports whether any word of text starts with query, ignoring case.
+func matchesPrefix(text, query string) bool {
+	if query == "" {
+		return true
+	}
+	query = strings.ToLower(query)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		if strings.HasPrefix(word, query) {
+			return true
+		}
+	}
+	return strings.HasPrefix(strings.ToLower(text), query)
+}
+
+// normalizeSearchQuery cleans up a search prefix typed by the user. Colons would break callback data.
+func normalizeSearchQuery(text string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(text, ":", " ")), " ")
+}
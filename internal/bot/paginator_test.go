@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"testing"
+
+	"gopkg.in/telebot.v3"
+)
+
+func TestPageListCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		count    int
+		page     int
+		wantNav  []string
+		wantPage int
+	}{
+		{"single page", 10, 0, nil, 1},
+		{"first page", 40, 0, []string{"1/3", "›"}, 3},
+		{"middle page", 40, 1, []string{"‹", "2/3", "›"}, 3},
+		{"last page", 40, 2, []string{"‹", "3/3"}, 3},
+		{"page past the end", 40, 7, []string{"‹", "3/3"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := pageList{Count: tt.count, PageSize: 15, Page: tt.page, Route: "histlog::0"}
+			if got := list.Pages(); got != tt.wantPage {
+				t.Errorf("Pages() = %d, want %d", got, tt.wantPage)
+			}
+			rows := list.Rows(&telebot.ReplyMarkup{})
+			var nav []string
+			if len(rows) > 0 {
+				for _, btn := range rows[len(rows)-1] {
+					nav = append(nav, btn.Text)
+				}
+			}
+			if len(rows) > 1 {
+				t.Errorf("Rows() has %d rows, want only navigation", len(rows))
+			}
+			if len(nav) != len(tt.wantNav) {
+				t.Fatalf("navigation = %q, want %q", nav, tt.wantNav)
+			}
+			for i := range nav {
+				if nav[i] != tt.wantNav[i] {
+					t.Errorf("navigation = %q, want %q", nav, tt.wantNav)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitListingDataHistory(t *testing.T) {
+	route, page, query, err := splitListingData([]string{"histlog", "", "0", "2", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(route) != 3 || route[1] != "" || route[2] != "0" || page != 2 || query != "" {
+		t.Errorf("splitListingData() = %q, %d, %q", route, page, query)
+	}
+	if _, _, _, err := splitListingData([]string{""}); err == nil {
+		t.Error("splitListingData() accepted empty data")
+	}
+}
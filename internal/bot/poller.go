@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/config"
+)
+
+const (
+	longPollerTimeout     = 10 * time.Second
+	webhookReadTimeout    = 10 * time.Second
+	webhookShutdownPeriod = 5 * time.Second
+	secretTokenHeader     = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+// NewPoller returns the poller for the configured bot mode, long polling unless BOT_MODE is webhook.
+func NewPoller(cfg *config.Config, log *logrus.Logger) telebot.Poller {
+	if cfg.BotMode != config.BotModeWebhook {
+		return &telebot.LongPoller{Timeout: longPollerTimeout}
+	}
+	return &webhookPoller{cfg: cfg.Webhook, log: log}
+}
+
+// PrepareMode makes the bot API state match the poller: Telegram refuses long polling while a webhook
+// is set, so it is removed when the bot runs in polling mode.
+func PrepareMode(b *telebot.Bot, cfg *config.Config) error {
+	if cfg.BotMode == config.BotModeWebhook {
+		return nil
+	}
+	return b.RemoveWebhook()
+}
+
+// webhookPoller registers the webhook with Telegram and serves it until the bot stops. Unlike
+// telebot.Webhook it answers requests without the secret token with 401 instead of silently dropping them.
+type webhookPoller struct {
+	cfg config.WebhookConfig
+	log *logrus.Logger
+}
+
+func (p *webhookPoller) Poll(b *telebot.Bot, dest chan telebot.Update, stop chan struct{}) {
+	webhook := &telebot.Webhook{
+		SecretToken: p.cfg.SecretToken,
+		Endpoint:    &telebot.WebhookEndpoint{PublicURL: p.cfg.PublicURL, Cert: p.cfg.TLSCert},
+	}
+	if err := b.SetWebhook(webhook); err != nil {
+		p.log.WithError(err).Fatal("error setting webhook")
+	}
+
+	server := &http.Server{
+		Addr:              p.cfg.Listen,
+		Handler:           requireSecretToken(p.cfg.SecretToken, updatesHandler(dest, p.log), p.log),
+		ReadHeaderTimeout: webhookReadTimeout,
+	}
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownPeriod)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			p.log.WithError(err).Error("error shutting down webhook server")
+		}
+	}()
+
+	var err error
+	if p.cfg.TLSCert != "" {
+		err = server.ListenAndServeTLS(p.cfg.TLSCert, p.cfg.TLSKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		p.log.WithError(err).Fatal("error serving webhook")
+	}
+}
+
+// requireSecretToken rejects webhook requests that do not carry the secret token Telegram was given.
+func requireSecretToken(secretToken string, next http.Handler, log *logrus.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(secretTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secretToken)) != 1 {
+			log.WithField("remote_addr", r.RemoteAddr).Warn("webhook request with invalid secret token")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func updatesHandler(dest chan<- telebot.Update, log *logrus.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var update telebot.Update
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			log.WithError(err).Warn("error decoding webhook update")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case dest <- update:
+		case <-r.Context().Done():
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiterSweepPeriod is how often buckets that have refilled completely are dropped.
+const rateLimiterSweepPeriod = 10 * time.Minute
+
+// rateLimiter is a token bucket per chat: a chat spends a token per update and earns perSecond tokens
+// a second, up to burst.
+type rateLimiter struct {
+	perSecond float64
+	burst     float64
+
+	mu        sync.Mutex
+	buckets   map[int64]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// warned is set once the chat has been told about the limit, so a flood gets a single reply.
+	warned bool
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		perSecond: perSecond,
+		burst:     float64(burst),
+		buckets:   make(map[int64]*tokenBucket),
+	}
+}
+
+// Allow spends a token of the chat. When the bucket is empty it reports whether the chat should be told
+// about the limit, which is true for the first rejection in a row only.
+func (l *rateLimiter) Allow(chatID int64, now time.Time) (allowed, warn bool) {
+	if l.perSecond <= 0 {
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[chatID]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[chatID] = bucket
+	}
+	bucket.tokens = min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.perSecond)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		warn = !bucket.warned
+		bucket.warned = true
+		return false, warn
+	}
+	bucket.tokens--
+	bucket.warned = false
+	return true, false
+}
+
+// sweep forgets the chats whose buckets are full again, they behave exactly like new ones.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimiterSweepPeriod {
+		return
+	}
+	l.lastSweep = now
+	refill := time.Duration(l.burst / l.perSecond * float64(time.Second))
+	for chatID, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= refill {
+			delete(l.buckets, chatID)
+		}
+	}
+}
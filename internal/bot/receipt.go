@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // photos arrive as JPEG
+	_ "image/png"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+// transactionTimeSeparator separates the amount of transaction callback data from the Unix time
+// of the transaction, which is present when it is known, e.g. from a receipt: "<amount>@<unix time>".
+const transactionTimeSeparator = "@"
+
+func formatTransactionAmount(amount float64, createdAt time.Time) string {
+	text := strconv.FormatFloat(amount, 'f', -1, 64)
+	if createdAt.IsZero() {
+		return text
+	}
+	return text + transactionTimeSeparator + strconv.FormatInt(createdAt.Unix(), 10)
+}
+
+// parseTransactionAmount reads the amount part of transaction callback data. The time is zero when
+// the data carries none.
+func parseTransactionAmount(data string) (float64, time.Time, error) {
+	amountPart, timePart, hasTime := strings.Cut(data, transactionTimeSeparator)
+	amount, err := strconv.ParseFloat(amountPart, 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if !hasTime {
+		return amount, time.Time{}, nil
+	}
+	unix, err := strconv.ParseInt(timePart, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return amount, time.Unix(unix, 0), nil
+}
+
+// amountText returns the amount of transaction callback data as the user typed it.
+func amountText(data string) string {
+	amount, _, _ := strings.Cut(data, transactionTimeSeparator)
+	return amount
+}
+
+var errNoQRCode = errors.New("no QR code found")
+
+// decodeQRCode finds and decodes a QR code in the image.
+func decodeQRCode(img image.Image) (string, error) {
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", err
+	}
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	result, err := qrcode.NewQRCodeReader().Decode(bitmap, hints)
+	if err != nil {
+		if _, ok := err.(gozxing.NotFoundException); ok {
+			return "", errNoQRCode
+		}
+		return "", err
+	}
+	return result.GetText(), nil
+}
+
+// receiptPicker offers the category keyboard for a transaction pre-filled from the receipt.
+func receiptPicker(categories []model.Category, receipt model.Receipt) (string, *telebot.ReplyMarkup) {
+	transactionType := receipt.TransactionType()
+	transactionData := strconv.Itoa(int(transactionType)) + ":" + formatTransactionAmount(receipt.Total, receipt.Time)
+	text, markup := categoryPickerPage(categories, 0, transactionData, 0, "")
+
+	kind := "Расход"
+	if transactionType == model.TransactionTypeIncome {
+		kind = "Доход"
+	}
+	summary := fmt.Sprintf("🧾 Чек от %s: %s %.2f", receipt.Time.Format("02.01.2006 15:04"), kind, receipt.Total)
+	return summary + "\n" + text, markup
+}
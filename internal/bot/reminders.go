@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+const (
+	reminderQuickCategories = 6
+	reminderSnooze          = time.Hour
+)
+
+// reminderMarkup offers the most used categories for quick entry and a snooze button.
+func reminderMarkup(storageInstance *storage.Storage, chatID int64) (*telebot.ReplyMarkup, error) {
+	categories, err := storageInstance.GetMostUsedCategories(chatID, reminderQuickCategories)
+	if err != nil {
+		return nil, err
+	}
+
+	markup := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	var row telebot.Row
+	for i, category := range categories {
+		row = append(row, markup.Data(category.Label(), "quick:"+strconv.FormatInt(category.ID, 10)))
+		if (i+1)%3 == 0 || i == len(categories)-1 {
+			rows = append(rows, row)
+			row = telebot.Row{}
+		}
+	}
+	rows = append(rows, markup.Row(markup.Data("⏰ Напомнить через час", "remind_snooze")))
+	markup.Inline(rows...)
+	return markup, nil
+}
+
+// sendReminders reminds users who chose to be reminded and have not added a transaction today.
+func sendReminders(t *throttle, storageInstance *storage.Storage, log *logrus.Logger, now time.Time) {
+	settings, err := storageInstance.GetReminderSettings()
+	if err != nil {
+		log.WithError(err).Error("error getting reminder settings")
+		return
+	}
+
+	for _, r := range settings {
+		if !r.IsDue(now) {
+			continue
+		}
+		start, end := r.Today(now)
+
+		from, to := storedRange(start, end)
+		logged, err := storageInstance.HasTransactionsBetween(r.ChatID, from, to)
+		if err != nil {
+			log.WithField("chat_id", r.ChatID).WithError(err).Error("error checking today's transactions")
+			continue
+		}
+		if !logged {
+			markup, err := reminderMarkup(storageInstance, r.ChatID)
+			if err != nil {
+				log.WithField("chat_id", r.ChatID).WithError(err).Error("error building reminder keyboard")
+				continue
+			}
+			text := "✍️ Сегодня ещё нет ни одной записи. Не забудьте внести траты: выберите категорию или " +
+				"просто отправьте сумму."
+			if err := sendText(t, telebot.ChatID(r.ChatID), text, markup); err != nil {
+				log.WithField("chat_id", r.ChatID).WithError(err).Error("error sending reminder")
+				continue
+			}
+		}
+
+		if err := storageInstance.MarkReminderSent(r.ChatID, start); err != nil {
+			log.WithField("chat_id", r.ChatID).WithError(err).Error("error marking reminder sent")
+		}
+	}
+}
+
+func formatReminderTime(sendMinute int) string {
+	return fmt.Sprintf("%02d:%02d", sendMinute/60, sendMinute%60)
+}
@@ -0,0 +1,30 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+func TestReminderStoredRange(t *testing.T) {
+	useLocal(t, time.UTC)
+	r := model.ReminderSettings{SendMinute: 21 * 60, Timezone: "Europe/Moscow"}
+
+	const layout = "2006-01-02 15:04"
+	tests := []struct {
+		now              time.Time
+		wantFrom, wantTo string
+	}{
+		// 22:30 in Moscow: the day began at 21:00 UTC of the previous server day
+		{time.Date(2024, 6, 1, 19, 30, 0, 0, time.UTC), "2024-05-31 21:00", "2024-06-01 21:00"},
+		// 00:30 in Moscow is already the next day, while the server is still on the previous one
+		{time.Date(2024, 6, 1, 21, 30, 0, 0, time.UTC), "2024-06-01 21:00", "2024-06-02 21:00"},
+	}
+	for _, tt := range tests {
+		from, to := storedRange(r.Today(tt.now))
+		if from.Location() != time.Local || from.Format(layout) != tt.wantFrom || to.Format(layout) != tt.wantTo {
+			t.Errorf("stored range at %v = %v – %v, want %s – %s in time.Local", tt.now, from, to, tt.wantFrom, tt.wantTo)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/render"
+)
+
+// sender is implemented by *telebot.Bot and by the throttle of the background jobs.
+type sender interface {
+	Send(to telebot.Recipient, what interface{}, opts ...interface{}) (*telebot.Message, error)
+}
+
+var renderOptions = &telebot.SendOptions{ParseMode: render.ParseMode}
+
+// sendRendered sends msg, split into several messages when it is too long for one. The markup is attached
+// to the last part.
+func sendRendered(s sender, to telebot.Recipient, msg *render.Message, markup *telebot.ReplyMarkup) error {
+	parts := msg.Parts()
+	for i, part := range parts {
+		opts := []interface{}{renderOptions}
+		if i == len(parts)-1 {
+			opts = append(opts, markup)
+		}
+		if _, err := s.Send(to, part, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// editRendered replaces the text of a message sent by sendRendered. A message that no longer fits into one
+// is sent anew instead.
+func editRendered(b *telebot.Bot, c *telebot.Callback, msg *render.Message, markup *telebot.ReplyMarkup) error {
+	parts := msg.Parts()
+	if len(parts) > 1 {
+		return sendRendered(b, c.Sender, msg, markup)
+	}
+	_, err := b.Edit(c.Message, parts[0], renderOptions, markup)
+	return err
+}
+
+// sendText sends plain text the way sendRendered sends a report, escaped and split when it is too long.
+func sendText(s sender, to telebot.Recipient, text string, markup *telebot.ReplyMarkup) error {
+	return sendRendered(s, to, render.New().Text(text), markup)
+}
+
+// editText replaces the text of a message with plain text, see editRendered.
+func editText(b *telebot.Bot, c *telebot.Callback, text string, markup *telebot.ReplyMarkup) error {
+	return editRendered(b, c, render.New().Text(text), markup)
+}
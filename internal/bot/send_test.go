@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/render"
+)
+
+type recordingSender struct {
+	texts []string
+	opts  [][]interface{}
+}
+
+func (s *recordingSender) Send(_ telebot.Recipient, what interface{}, opts ...interface{}) (*telebot.Message, error) {
+	s.texts = append(s.texts, what.(string))
+	s.opts = append(s.opts, opts)
+	return &telebot.Message{}, nil
+}
+
+func TestSendText(t *testing.T) {
+	s := &recordingSender{}
+	markup := &telebot.ReplyMarkup{}
+	if err := sendText(s, telebot.ChatID(1), "Категория 'a_b*c <x>' успешно добавлена.", markup); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.texts) != 1 || s.texts[0] != "Категория 'a_b*c &lt;x&gt;' успешно добавлена." {
+		t.Fatalf("sendText() sent %q", s.texts)
+	}
+	if len(s.opts[0]) != 2 || s.opts[0][0] != renderOptions || s.opts[0][1] != markup {
+		t.Errorf("sendText() options = %v, want the render options and the markup", s.opts[0])
+	}
+
+	s = &recordingSender{}
+	long := strings.Repeat("Транзакция & подробности\n", render.MaxLength/10)
+	if err := sendText(s, telebot.ChatID(1), long, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.texts) < 2 {
+		t.Errorf("sendText() of %d characters sent %d messages", len(long), len(s.texts))
+	}
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"sync"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+// sessionStore keeps the dialog state of every user. Updates are handled concurrently, so access is guarded.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[int64]*model.UserSession
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[int64]*model.UserSession)}
+}
+
+func (s *sessionStore) Get(userID int64) (*model.UserSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[userID]
+	return session, ok
+}
+
+func (s *sessionStore) Set(userID int64, session *model.UserSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[userID] = session
+}
+
+func (s *sessionStore) Delete(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, userID)
+}
+
+func (s *sessionStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// ActiveSessions returns the number of users in the middle of a multi-step dialog.
+func ActiveSessions() int {
+	return userSessions.Len()
+}
@@ -0,0 +1,204 @@
+package bot
+
+import (
+	"strconv"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+// weeklyBreakdownDays is the longest period that the detailed report still lists day by day.
+const weeklyBreakdownDays = 31
+
+var (
+	weekdayNames = [...]string{"Вс", "Пн", "Вт", "Ср", "Чт", "Пт", "Сб"}
+	// monthNames holds the accusative ("на май") and the prepositional ("в мае") forms of every month.
+	monthNames = [...][2]string{
+		{"январь", "январе"}, {"февраль", "феврале"}, {"март", "марте"}, {"апрель", "апреле"},
+		{"май", "мае"}, {"июнь", "июне"}, {"июль", "июле"}, {"август", "августе"},
+		{"сентябрь", "сентябре"}, {"октябрь", "октябре"}, {"ноябрь", "ноябре"}, {"декабрь", "декабре"},
+	}
+)
+
+// buildStats renders the stats message for the period together with the buttons that toggle
+// subcategories and open the detailed report. top is the number of categories listed before "Прочее".
+func buildStats(
+	storageInstance *storage.Storage,
+	chatID int64,
+	startDate, endDate time.Time,
+	expanded bool,
+	top int,
+) (*render.Message, *telebot.ReplyMarkup, error) {
+	from, to := storedRange(startDate, endDate)
+	rows, err := storageInstance.GetTransactionsStatsByCategory(chatID, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	categories, err := storageInstance.GetCategoriesByChatID(chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tree := newCategoryTree(categories)
+
+	period := strconv.FormatInt(startDate.Unix(), 10) + ":" + strconv.FormatInt(endDate.Unix(), 10)
+	markup := &telebot.ReplyMarkup{}
+	buttons := []telebot.Row{markup.Row(markup.Data("📅 По дням", "stats_daily:"+period))}
+	if hasSubcategoryTotals(tree, rows) {
+		text, flag := "Показать подкатегории", "1"
+		if expanded {
+			text, flag = "Скрыть подкатегории", "0"
+		}
+		buttons = append(buttons, markup.Row(markup.Data(text, "stats_tree:"+flag+":"+period)))
+	}
+	markup.Inline(buttons...)
+
+	return getStats(tree, rows, expanded, top), markup, nil
+}
+
+// buildDailyStats renders the detailed report of the period: expenses per day, or per week for periods
+// longer than a month, the average daily spend and the highest day, followed by the forecast for the
+// current month. Days are cut in the user's time zone.
+func buildDailyStats(
+	storageInstance *storage.Storage,
+	chatID int64,
+	startDate, endDate, now time.Time,
+) (*render.Message, error) {
+	user, err := storageInstance.GetUserByChatID(chatID)
+	if err != nil {
+		return nil, err
+	}
+	loc := user.Location()
+
+	// Stored times are server-local, so the period and the month bounds are passed in time.Local.
+	from, to := storedRange(startDate, endDate)
+	days, err := storageInstance.GetDailyExpenses(chatID, from, to, loc.String())
+	if err != nil {
+		return nil, err
+	}
+
+	local := now.In(loc)
+	monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+	monthDays, err := storageInstance.GetDailyExpenses(chatID,
+		monthStart.AddDate(0, -1, 0).In(time.Local), monthStart.AddDate(0, 1, 0).In(time.Local), loc.String())
+	if err != nil {
+		return nil, err
+	}
+
+	response := render.New()
+	first, last := periodDates(startDate, endDate, loc)
+	writeBreakdown(response, days, first, last, dateOf(local))
+	response.Line()
+	writeForecast(response, monthDays, dateOf(local))
+	return response, nil
+}
+
+// writeBreakdown lists the expenses of the days first..last, days without expenses included. Today
+// bounds the average so that a period reaching into the future is not diluted by days yet to come.
+func writeBreakdown(response *render.Message, days []model.DailyAmount, first, last, today time.Time) {
+	amounts := make(map[time.Time]float64, len(days))
+	for _, d := range days {
+		amounts[dateOf(d.Day)] += d.Amount
+	}
+
+	weekly := last.Sub(first) >= weeklyBreakdownDays*24*time.Hour
+	if weekly {
+		response.Text("📅 ").Bold("Расходы по неделям").Line().Line()
+	} else {
+		response.Text("📅 ").Bold("Расходы по дням").Line().Line()
+	}
+
+	var total, maxAmount, weekAmount float64
+	var maxDay, weekStart time.Time
+	elapsed := 0
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		amount := amounts[day]
+		total += amount
+		if !day.After(today) {
+			elapsed++
+		}
+		if amount > maxAmount {
+			maxAmount, maxDay = amount, day
+		}
+
+		if !weekly {
+			response.Textf("%s %s: %.1f", day.Format("02.01"), weekdayNames[day.Weekday()], amount).Line()
+			continue
+		}
+		if weekStart.IsZero() {
+			weekStart = day
+		}
+		weekAmount += amount
+		if day.Weekday() == time.Sunday || day.Equal(last) {
+			response.Textf("%s–%s: %.1f", weekStart.Format("02.01"), day.Format("02.01"), weekAmount).Line()
+			weekStart, weekAmount = time.Time{}, 0
+		}
+	}
+
+	response.Line().Text("💸 ").Bold("Всего").Textf(": %.1f", total).Line()
+	if elapsed > 0 {
+		response.Text("📉 ").Bold("В среднем за день").Textf(": %.1f", total/float64(elapsed)).Line()
+	}
+	if maxAmount > 0 {
+		response.Text("🔝 ").Bold("Самый затратный день").
+			Textf(": %s %s, %.1f", maxDay.Format("02.01"), weekdayNames[maxDay.Weekday()], maxAmount).Line()
+	}
+}
+
+// writeForecast projects the expenses of the current month from its pace so far and compares the
+// projection with the previous month. days covers both months.
+func writeForecast(response *render.Message, days []model.DailyAmount, today time.Time) {
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var spent, previous float64
+	for _, d := range days {
+		if dateOf(d.Day).Before(monthStart) {
+			previous += d.Amount
+		} else {
+			spent += d.Amount
+		}
+	}
+
+	daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+	forecast := spent / float64(today.Day()) * float64(daysInMonth)
+	month := monthNames[today.Month()-1]
+	previousMonth := monthNames[monthStart.AddDate(0, -1, 0).Month()-1]
+
+	response.Text("🔮 ").Boldf("Прогноз на %s", month[0]).Textf(": %.1f", forecast).Line()
+	response.Textf("Потрачено за %d из %d дн.: %.1f", today.Day(), daysInMonth, spent).Line()
+	switch {
+	case previous == 0:
+		response.Textf("В %s расходов не было.", previousMonth[1])
+	case forecast >= previous:
+		response.Textf("Это на %.0f%% больше, чем в %s (%.1f).",
+			(forecast-previous)/previous*100, previousMonth[1], previous)
+	default:
+		response.Textf("Это на %.0f%% меньше, чем в %s (%.1f).",
+			(previous-forecast)/previous*100, previousMonth[1], previous)
+	}
+}
+
+// storedRange expresses the period [start, end) in time.Local for a query. created_at is a timestamp without
+// time zone holding server-local times, and pgx passes only the wall clock of a time to such a column.
+func storedRange(start, end time.Time) (time.Time, time.Time) {
+	return start.In(time.Local), end.In(time.Local)
+}
+
+// dateOf returns the calendar day of t's wall clock as midnight UTC, the form in which dates are scanned.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// periodDates returns the first and the last calendar day of the period in loc, the zone its days are
+// bucketed in.
+func periodDates(start, end time.Time, loc *time.Location) (time.Time, time.Time) {
+	return dateOf(start.In(loc)), lastDateOf(end.In(loc))
+}
+
+// lastDateOf returns the last calendar day before the exclusive period end.
+func lastDateOf(end time.Time) time.Time {
+	return dateOf(end.Add(-time.Nanosecond))
+}
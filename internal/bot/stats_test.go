@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestWriteBreakdown(t *testing.T) {
+	tests := []struct {
+		name               string
+		days               []model.DailyAmount
+		first, last, today time.Time
+		want, notWant      []string
+	}{
+		{
+			name:  "period reaching into the future",
+			days:  []model.DailyAmount{{Day: date(2024, 6, 3), Amount: 30}, {Day: date(2024, 6, 5), Amount: 20}},
+			first: date(2024, 6, 3), last: date(2024, 6, 9), today: date(2024, 6, 5),
+			want: []string{
+				"<b>Расходы по дням</b>",
+				"03.06 Пн: 30.0\n", "06.06 Чт: 0.0\n", "09.06 Вс: 0.0\n",
+				"<b>Всего</b>: 50.0",
+				// three days have passed, not seven
+				"<b>В среднем за день</b>: 16.7",
+				"<b>Самый затратный день</b>: 03.06 Пн, 30.0",
+			},
+		},
+		{
+			name:  "first day of a month",
+			days:  []model.DailyAmount{{Day: date(2024, 7, 1), Amount: 12}},
+			first: date(2024, 7, 1), last: date(2024, 7, 31), today: date(2024, 7, 1),
+			want:    []string{"<b>Расходы по дням</b>", "01.07 Пн: 12.0\n", "31.07 Ср: 0.0\n", "<b>В среднем за день</b>: 12.0"},
+			notWant: []string{"по неделям"},
+		},
+		{
+			name: "period longer than 31 days",
+			days: []model.DailyAmount{
+				{Day: date(2024, 6, 1), Amount: 10}, {Day: date(2024, 6, 2), Amount: 5}, {Day: date(2024, 7, 15), Amount: 7},
+			},
+			first: date(2024, 6, 1), last: date(2024, 7, 15), today: date(2024, 7, 20),
+			want: []string{
+				"<b>Расходы по неделям</b>",
+				"01.06–02.06: 15.0\n", "03.06–09.06: 0.0\n", "08.07–14.07: 0.0\n", "15.07–15.07: 7.0\n",
+				"<b>Всего</b>: 22.0",
+				"<b>В среднем за день</b>: 0.5",
+				"<b>Самый затратный день</b>: 01.06 Сб, 10.0",
+			},
+			notWant: []string{"01.06 Сб: 10.0"},
+		},
+		{
+			name:  "no expenses",
+			first: date(2024, 6, 3), last: date(2024, 6, 4), today: date(2024, 6, 10),
+			want:    []string{"03.06 Пн: 0.0\n", "<b>Всего</b>: 0.0", "<b>В среднем за день</b>: 0.0"},
+			notWant: []string{"Самый затратный день"},
+		},
+		{
+			name:  "period in the future",
+			first: date(2024, 6, 10), last: date(2024, 6, 11), today: date(2024, 6, 3),
+			want:    []string{"<b>Всего</b>: 0.0"},
+			notWant: []string{"В среднем за день"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := render.New()
+			writeBreakdown(response, tt.days, tt.first, tt.last, tt.today)
+			text := response.String()
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("writeBreakdown() = %q, want it to contain %q", text, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(text, notWant) {
+					t.Errorf("writeBreakdown() = %q, want it not to contain %q", text, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteBreakdownUserZone(t *testing.T) {
+	useLocal(t, time.UTC)
+	moscow := time.FixedZone("MSK", 3*60*60)
+	// today of a Moscow user, 2024-06-04 21:00–2024-06-05 21:00 UTC
+	start := time.Date(2024, 6, 5, 0, 0, 0, 0, moscow)
+	end := start.AddDate(0, 0, 1)
+
+	first, last := periodDates(start.In(time.Local), end.In(time.Local), moscow)
+	if !first.Equal(date(2024, 6, 5)) || !last.Equal(date(2024, 6, 5)) {
+		t.Fatalf("periodDates() = %v, %v, want 2024-06-05 twice", first, last)
+	}
+
+	// spent at 22:00 UTC, already the 5th in Moscow
+	days := []model.DailyAmount{{Day: date(2024, 6, 5), Amount: 40}}
+	response := render.New()
+	writeBreakdown(response, days, first, last, date(2024, 6, 5))
+	text := response.String()
+	for _, want := range []string{"05.06 Ср: 40.0\n", "<b>Всего</b>: 40.0"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("writeBreakdown() = %q, want it to contain %q", text, want)
+		}
+	}
+	if strings.Contains(text, "04.06") {
+		t.Errorf("writeBreakdown() = %q, want it not to list 04.06", text)
+	}
+}
+
+func TestWriteForecast(t *testing.T) {
+	tests := []struct {
+		name  string
+		days  []model.DailyAmount
+		today time.Time
+		want  []string
+	}{
+		{
+			name:  "first day of a month",
+			days:  []model.DailyAmount{{Day: date(2024, 5, 10), Amount: 300}, {Day: date(2024, 6, 1), Amount: 10}},
+			today: date(2024, 6, 1),
+			want: []string{
+				"<b>Прогноз на июнь</b>: 300.0",
+				"Потрачено за 1 из 30 дн.: 10.0",
+				"Это на 0% больше, чем в мае (300.0).",
+			},
+		},
+		{
+			name:  "previous month with zero expenses",
+			days:  []model.DailyAmount{{Day: date(2024, 3, 1), Amount: 150}},
+			today: date(2024, 3, 15),
+			want: []string{
+				"<b>Прогноз на март</b>: 310.0",
+				"Потрачено за 15 из 31 дн.: 150.0",
+				"В феврале расходов не было.",
+			},
+		},
+		{
+			name:  "below the previous month",
+			days:  []model.DailyAmount{{Day: date(2024, 5, 20), Amount: 1000}, {Day: date(2024, 6, 10), Amount: 300}},
+			today: date(2024, 6, 15),
+			want:  []string{"<b>Прогноз на июнь</b>: 600.0", "Это на 40% меньше, чем в мае (1000.0)."},
+		},
+		{
+			name:  "previous month in the previous year",
+			days:  []model.DailyAmount{{Day: date(2023, 12, 31), Amount: 100}, {Day: date(2024, 1, 2), Amount: 20}},
+			today: date(2024, 1, 2),
+			want:  []string{"<b>Прогноз на январь</b>: 310.0", "Это на 210% больше, чем в декабре (100.0)."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := render.New()
+			writeForecast(response, tt.days, tt.today)
+			text := response.String()
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("writeForecast() = %q, want it to contain %q", text, want)
+				}
+			}
+		})
+	}
+}
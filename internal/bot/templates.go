@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+const (
+	// Texts of the quick-add reply keyboard. A tap sends the text of the button, which handleQuickButton
+	// recognizes by these prefixes.
+	repeatButtonText     = "🔁 Повторить последнюю"
+	categoryButtonPrefix = "➕ "
+	templateButtonPrefix = "⭐ "
+
+	quickKeyboardCategories = 4
+	maxTemplates            = 12
+)
+
+// quickKeyboard is the persistent reply keyboard with the top categories, the templates and the button
+// repeating the last transaction.
+func quickKeyboard(categories []model.Category, templates []model.Template) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{ResizeKeyboard: true, IsPersistent: true}
+
+	var buttons []telebot.Btn
+	for _, category := range categories {
+		buttons = append(buttons, markup.Text(categoryButtonPrefix+category.Label()))
+	}
+	for _, template := range templates {
+		buttons = append(buttons, markup.Text(templateButtonPrefix+template.Label()))
+	}
+
+	rows := markup.Split(2, buttons)
+	rows = append(rows, markup.Row(markup.Text(repeatButtonText)))
+	markup.Reply(rows...)
+	return markup
+}
+
+// loadQuickKeyboard builds the quick-add keyboard of the chat.
+func loadQuickKeyboard(storageInstance *storage.Storage, chatID int64) (*telebot.ReplyMarkup, error) {
+	categories, err := storageInstance.GetMostUsedCategories(chatID, quickKeyboardCategories)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := storageInstance.GetTemplatesByChatID(chatID)
+	if err != nil {
+		return nil, err
+	}
+	return quickKeyboard(categories, templates), nil
+}
+
+// findCategory returns the category whose keyboard button has the text.
+func findCategory(categories []model.Category, text string) (model.Category, bool) {
+	label := strings.TrimPrefix(text, categoryButtonPrefix)
+	for _, category := range categories {
+		if category.Label() == label {
+			return category, true
+		}
+	}
+	return model.Category{}, false
+}
+
+// templateList renders /templates: a button booking each template and one deleting it.
+func templateList(templates []model.Template) (string, *telebot.ReplyMarkup) {
+	if len(templates) == 0 {
+		return "Шаблонов пока нет. Чтобы создать шаблон, добавьте транзакцию и нажмите «⭐ В шаблоны».", nil
+	}
+
+	markup := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	for _, template := range templates {
+		id := strconv.FormatInt(template.ID, 10)
+		rows = append(rows, markup.Row(
+			markup.Data(template.Label(), "tpl:"+id),
+			markup.Data("✕", "tpl_del:"+id),
+		))
+	}
+	markup.Inline(rows...)
+	return "Шаблоны. Нажмите на шаблон, чтобы добавить транзакцию, или ✕, чтобы удалить его:", markup
+}
+
+// findTemplate returns the template whose keyboard button has the text.
+func findTemplate(templates []model.Template, text string) (model.Template, bool) {
+	label := strings.TrimPrefix(text, templateButtonPrefix)
+	for _, template := range templates {
+		if template.Label() == label {
+			return template, true
+		}
+	}
+	return model.Template{}, false
+}
+
+// bookTemplate adds the transaction of the template and returns its ID with the confirmation text.
+func bookTemplate(storageInstance *storage.Storage, template model.Template) (int64, string, error) {
+	transactionId, err := handleTransaction(
+		storageInstance,
+		template.ChatID,
+		template.AccountID,
+		template.CategoryID,
+		template.Amount,
+		template.TransactionType,
+		time.Time{},
+	)
+	if err != nil {
+		return 0, "", err
+	}
+	return transactionId, fmt.Sprintf("Транзакция «%s» на сумму %s добавлена", template.Name,
+		strconv.FormatFloat(template.Amount, 'f', -1, 64)), nil
+}
@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+// undoWindow is how long an action can be undone.
+const undoWindow = time.Hour
+
+// undoButton reverts the action of the confirmation message it is attached to, "undo:<kind>:<target id>".
+func undoButton(markup *telebot.ReplyMarkup, kind uint8, targetID int64) telebot.Btn {
+	data := "undo:" + strconv.Itoa(int(kind)) + ":" + strconv.FormatInt(targetID, 10)
+	return markup.Data("↩️ Отменить", data)
+}
+
+func undoMarkup(kind uint8, targetID int64) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(markup.Row(undoButton(markup, kind, targetID)))
+	return markup
+}
+
+// undoText describes the outcome of an undo, err being the error of storage.UndoLastAction.
+func undoText(action model.Action, err error) string {
+	switch {
+	case errors.Is(err, storage.ErrNothingToUndo):
+		return "Нечего отменять: действие уже отменено, сделано позже другого или старше часа."
+	case errors.Is(err, storage.ErrCategoryExists):
+		return "Нельзя вернуть прежнее название: категория с таким названием уже есть."
+	case err != nil:
+		return "Ошибка при отмене: " + err.Error()
+	}
+
+	switch action.Kind {
+	case model.ActionAddTransaction:
+		return fmt.Sprintf("Отменено: транзакция на сумму %.2f удалена.", action.Amount)
+	case model.ActionRenameCategory:
+		return "Отменено: категории возвращено название «" + action.OldValue + "»."
+	default:
+		return "Действие отменено."
+	}
+}
+
+// isUndoFailure reports whether the undo error is one the user is told about rather than an internal error.
+func isUndoFailure(err error) bool {
+	return errors.Is(err, storage.ErrNothingToUndo) || errors.Is(err, storage.ErrCategoryExists)
+}
@@ -0,0 +1,40 @@
+package bot
+
+import "gopkg.in/telebot.v3"
+
+const (
+	deleteMeText = "Будут удалены все ваши данные: транзакции, категории, счета, цели, долги, шаблоны, " +
+		"токен API и настройки.\n" +
+		"Перед удалением можно выгрузить их в JSON."
+	deleteMeConfirmText = "Вы уверены? Удалённые данные нельзя восстановить."
+	resetText           = "Удалить все транзакции? Категории, счета, цели и настройки сохранятся."
+)
+
+// deleteMeMarkup is the first step of /delete_me: export the data or go on to the final confirmation.
+func deleteMeMarkup() *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(
+		markup.Row(markup.Data("📦 Выгрузить данные", "delete_me:export")),
+		markup.Row(markup.Data("🗑 Удалить всё", "delete_me:confirm")),
+		markup.Row(markup.Data("Отмена", "delete_me:cancel")),
+	)
+	return markup
+}
+
+func deleteMeConfirmMarkup() *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(
+		markup.Row(markup.Data("Да, удалить навсегда", "delete_me:final")),
+		markup.Row(markup.Data("Отмена", "delete_me:cancel")),
+	)
+	return markup
+}
+
+func resetMarkup() *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		markup.Data("Да, удалить транзакции", "reset:confirm"),
+		markup.Data("Отмена", "reset:cancel"),
+	))
+	return markup
+}
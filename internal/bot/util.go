@@ -1,12 +1,22 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
+	"github.com/cupitman9/budget-bot/internal/storage"
 )
 
-func sumMapValues(m map[string]float64) float64 {
+// statsBarWidth is the number of cells in the share bar of a stats line.
+const statsBarWidth = 10
+
+func sumMapValues(m map[int64]float64) float64 {
 	var sum float64
 	for _, value := range m {
 		sum += value
@@ -18,25 +28,154 @@ func parseCategoryId(idStr string) (int64, error) {
 	return strconv.ParseInt(idStr, 10, 64)
 }
 
-func getStats(incomeCategories, expenseCategories map[string]float64) string {
+func getStats(tree *categoryTree, rows []model.CategoryStat, expanded bool, top int) *render.Message {
+	incomeCategories := categorySums(rows, model.TransactionTypeIncome)
+	expenseCategories := categorySums(rows, model.TransactionTypeExpense)
 	totalIncome := sumMapValues(incomeCategories)
 	totalExpense := sumMapValues(expenseCategories)
 	netIncome := totalIncome - totalExpense
 
-	var response strings.Builder
-	response.WriteString("📊 *Статистика за период*\n\n")
+	response := render.New()
+	response.Text("📊 ").Bold("Статистика за период").Line().Line()
+
+	response.Text("💰 ").Bold("Доход").Textf(": %.1f", totalIncome).Line()
+	writeCategoryTotals(response, tree, tree.rollUp(incomeCategories), totalIncome, expanded, top)
+
+	response.Line().Text("💸 ").Bold("Расход").Textf(": %.1f", totalExpense).Line()
+	writeCategoryTotals(response, tree, tree.rollUp(expenseCategories), totalExpense, expanded, top)
+
+	response.Line().Text("💹 ").Bold("Итого").Textf(": %.1f", netIncome)
+
+	return response
+}
+
+// writeCategoryTotals lists top-level categories with rolled-up totals, largest first, with their share of
+// total and, when expanded, their subcategories. Top-level categories beyond the first top are summed up as
+// "Прочее"; a zero top lists all of them.
+func writeCategoryTotals(
+	response *render.Message,
+	tree *categoryTree,
+	totals map[int64]float64,
+	total float64,
+	expanded bool,
+	top int,
+) {
+	categories := sortedByTotal(tree.children[0], totals)
+	var other float64
+	for i, c := range categories {
+		// A single category is shown as is rather than as "Прочее".
+		if top > 0 && i >= top && len(categories) > top+1 {
+			other += totals[c.ID]
+			continue
+		}
+		response.Textf("  - %s: %.1f %s", c.Label(), totals[c.ID], shareText(totals[c.ID], total)).Line()
+		if expanded {
+			writeSubcategoryTotals(response, tree, totals, total, c.ID, 1)
+		}
+	}
+	if other > 0 {
+		response.Textf("  - Прочее: %.1f %s", other, shareText(other, total)).Line()
+	}
+}
 
-	response.WriteString(fmt.Sprintf("💰 *Доход*: %.1f\n", totalIncome))
-	for category, amount := range incomeCategories {
-		response.WriteString(fmt.Sprintf("  - %s: %.1f\n", category, amount))
+func writeSubcategoryTotals(
+	response *render.Message,
+	tree *categoryTree,
+	totals map[int64]float64,
+	total float64,
+	parentID int64,
+	depth int,
+) {
+	for _, c := range sortedByTotal(tree.children[parentID], totals) {
+		response.Textf("%s↳ %s: %.1f (%.0f%%)",
+			strings.Repeat("    ", depth), c.Label(), totals[c.ID], share(totals[c.ID], total)*100).Line()
+		writeSubcategoryTotals(response, tree, totals, total, c.ID, depth+1)
 	}
+}
 
-	response.WriteString(fmt.Sprintf("\n💸 *Расход*: %.1f\n", totalExpense))
-	for category, amount := range expenseCategories {
-		response.WriteString(fmt.Sprintf("  - %s: %.1f\n", category, amount))
+// sortedByTotal returns the categories that have a total, largest first. Equal totals keep the input order.
+func sortedByTotal(categories []model.Category, totals map[int64]float64) []model.Category {
+	sorted := make([]model.Category, 0, len(categories))
+	for _, c := range categories {
+		if _, ok := totals[c.ID]; ok {
+			sorted = append(sorted, c)
+		}
 	}
+	sort.SliceStable(sorted, func(i, j int) bool { return totals[sorted[i].ID] > totals[sorted[j].ID] })
+	return sorted
+}
 
-	response.WriteString(fmt.Sprintf("\n💹 *Итого*: %.1f", netIncome))
+// shareText renders the share of amount in total as a percentage followed by a text bar, e.g. "(40%) ▓▓▓▓░░░░░░".
+func shareText(amount, total float64) string {
+	s := share(amount, total)
+	filled := int(math.Round(s * statsBarWidth))
+	return fmt.Sprintf("(%.0f%%) %s%s", s*100,
+		strings.Repeat("▓", filled), strings.Repeat("░", statsBarWidth-filled))
+}
 
-	return response.String()
+func share(amount, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return min(max(amount/total, 0), 1)
+}
+
+// categorySums returns the sums of the rows of one transaction type keyed by category ID.
+func categorySums(rows []model.CategoryStat, transactionType uint8) map[int64]float64 {
+	sums := make(map[int64]float64)
+	for _, row := range rows {
+		if row.TransactionType == transactionType {
+			sums[row.CategoryID] += row.Sum
+		}
+	}
+	return sums
+}
+
+// hasSubcategoryTotals reports whether expanding the stats would show any subcategory.
+func hasSubcategoryTotals(tree *categoryTree, rows []model.CategoryStat) bool {
+	for _, row := range rows {
+		if c, ok := tree.byID[row.CategoryID]; ok && c.ParentID != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// transactionErrorText explains why a transaction could not be booked.
+func transactionErrorText(err error) string {
+	switch {
+	case errors.Is(err, model.ErrUnknownCategory):
+		return "Категория не найдена. Возможно, она была удалена."
+	case errors.Is(err, model.ErrCategoryNotAllowed):
+		return "Эта категория не подходит для такого типа транзакции."
+	case errors.Is(err, model.ErrUnknownAccount):
+		return "Счёт не найден. Возможно, он был удалён."
+	case errors.Is(err, model.ErrTransferSameAccount):
+		return "Нельзя перевести деньги на тот же счёт."
+	case errors.Is(err, model.ErrAmountNotPositive):
+		return "Сумма должна быть положительным числом."
+	default:
+		return "Ошибка при создании и сохранении транзакции"
+	}
+}
+
+func nameErrorText(err error) string {
+	switch {
+	case errors.Is(err, model.ErrNameEmpty):
+		return "Название не может быть пустым. Введите другое название:"
+	case errors.Is(err, model.ErrNameTooLong):
+		return fmt.Sprintf("Название должно быть не длиннее %d символов. Введите другое название:",
+			model.NameMaxLength)
+	case errors.Is(err, model.ErrNameInvalidChars):
+		return "Название может содержать только буквы, цифры, пробелы и символы - . , ( ) & + ' / № %. " +
+			"Введите другое название:"
+	case errors.Is(err, storage.ErrCategoryExists):
+		return "Категория с таким названием уже есть. Введите другое название:"
+	case errors.Is(err, storage.ErrAccountExists):
+		return "Счёт с таким названием уже есть. Введите другое название:"
+	case errors.Is(err, storage.ErrTemplateExists):
+		return "Шаблон с таким названием уже есть. Введите другое название:"
+	default:
+		return "Ошибка при сохранении: " + err.Error()
+	}
 }
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/render"
+)
+
+func TestShareText(t *testing.T) {
+	tests := []struct {
+		amount, total float64
+		want          string
+	}{
+		{400, 1000, "(40%) ▓▓▓▓░░░░░░"},
+		{1, 3, "(33%) ▓▓▓░░░░░░░"},
+		{2, 3, "(67%) ▓▓▓▓▓▓▓░░░"},
+		{1000, 1000, "(100%) ▓▓▓▓▓▓▓▓▓▓"},
+		{0, 1000, "(0%) ░░░░░░░░░░"},
+		// no total and amounts out of range are clamped
+		{100, 0, "(0%) ░░░░░░░░░░"},
+		{1500, 1000, "(100%) ▓▓▓▓▓▓▓▓▓▓"},
+		{-100, 1000, "(0%) ░░░░░░░░░░"},
+	}
+	for _, tt := range tests {
+		if got := shareText(tt.amount, tt.total); got != tt.want {
+			t.Errorf("shareText(%v, %v) = %q, want %q", tt.amount, tt.total, got, tt.want)
+		}
+	}
+}
+
+// statsFixture is Еда, Транспорт with the subcategory Такси, Кино, Книги and Связь, booked so that the
+// expenses total 1000, and Зарплата with an income of 1500.
+func statsFixture() ([]model.Category, []model.CategoryStat) {
+	categories := []model.Category{
+		{ID: 1, Name: "Еда", Icon: "🍔"},
+		{ID: 2, Name: "Транспорт"},
+		{ID: 3, Name: "Такси", ParentID: 2},
+		{ID: 4, Name: "Кино"},
+		{ID: 5, Name: "Книги"},
+		{ID: 6, Name: "Связь"},
+		{ID: 7, Name: "Зарплата"},
+	}
+	rows := []model.CategoryStat{
+		{CategoryID: 1, TransactionType: model.TransactionTypeExpense, Sum: 400},
+		{CategoryID: 3, TransactionType: model.TransactionTypeExpense, Sum: 200},
+		{CategoryID: 4, TransactionType: model.TransactionTypeExpense, Sum: 150},
+		{CategoryID: 5, TransactionType: model.TransactionTypeExpense, Sum: 150},
+		{CategoryID: 6, TransactionType: model.TransactionTypeExpense, Sum: 100},
+		{CategoryID: 7, TransactionType: model.TransactionTypeIncome, Sum: 1500},
+	}
+	return categories, rows
+}
+
+func TestWriteCategoryTotals(t *testing.T) {
+	categories, rows := statsFixture()
+	tree := newCategoryTree(categories)
+	totals := tree.rollUp(categorySums(rows, model.TransactionTypeExpense))
+
+	tests := []struct {
+		name     string
+		top      int
+		expanded bool
+		want     string
+	}{
+		{
+			name: "top two",
+			top:  2,
+			want: "  - 🍔 Еда: 400.0 (40%) ▓▓▓▓░░░░░░\n" +
+				"  - Транспорт: 200.0 (20%) ▓▓░░░░░░░░\n" +
+				"  - Прочее: 400.0 (40%) ▓▓▓▓░░░░░░\n",
+		},
+		{
+			name:     "top two expanded",
+			top:      2,
+			expanded: true,
+			want: "  - 🍔 Еда: 400.0 (40%) ▓▓▓▓░░░░░░\n" +
+				"  - Транспорт: 200.0 (20%) ▓▓░░░░░░░░\n" +
+				"    ↳ Такси: 200.0 (20%)\n" +
+				"  - Прочее: 400.0 (40%) ▓▓▓▓░░░░░░\n",
+		},
+		{
+			name: "a single category is not grouped",
+			top:  4,
+			want: "  - 🍔 Еда: 400.0 (40%) ▓▓▓▓░░░░░░\n" +
+				"  - Транспорт: 200.0 (20%) ▓▓░░░░░░░░\n" +
+				"  - Кино: 150.0 (15%) ▓▓░░░░░░░░\n" +
+				"  - Книги: 150.0 (15%) ▓▓░░░░░░░░\n" +
+				"  - Связь: 100.0 (10%) ▓░░░░░░░░░\n",
+		},
+		{
+			name: "zero top lists all",
+			top:  0,
+			want: "  - 🍔 Еда: 400.0 (40%) ▓▓▓▓░░░░░░\n" +
+				"  - Транспорт: 200.0 (20%) ▓▓░░░░░░░░\n" +
+				"  - Кино: 150.0 (15%) ▓▓░░░░░░░░\n" +
+				"  - Книги: 150.0 (15%) ▓▓░░░░░░░░\n" +
+				"  - Связь: 100.0 (10%) ▓░░░░░░░░░\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := render.New()
+			writeCategoryTotals(response, tree, totals, 1000, tt.expanded, tt.top)
+			if got := response.String(); got != tt.want {
+				t.Errorf("writeCategoryTotals() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	categories, rows := statsFixture()
+	categories = append(categories, model.Category{ID: 8, Name: "Кафе & <бары>"})
+	rows = append(rows, model.CategoryStat{CategoryID: 8, TransactionType: model.TransactionTypeExpense, Sum: 50})
+
+	text := getStats(newCategoryTree(categories), rows, false, 0).String()
+	for _, want := range []string{
+		"💰 <b>Доход</b>: 1500.0\n  - Зарплата: 1500.0 (100%) ▓▓▓▓▓▓▓▓▓▓\n",
+		"💸 <b>Расход</b>: 1050.0\n",
+		"  - Кафе &amp; &lt;бары&gt;: 50.0 (5%) ░░░░░░░░░░\n",
+		"💹 <b>Итого</b>: 450.0",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("getStats() = %q, want it to contain %q", text, want)
+		}
+	}
+}
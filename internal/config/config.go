@@ -1,15 +1,68 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/caarlos0/env/v10"
 )
 
+const (
+	BotModePolling = "polling"
+	BotModeWebhook = "webhook"
+)
+
+// secretTokenPattern is the format Telegram accepts for the webhook secret token.
+var secretTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
 type Config struct {
-	BotToken    string `env:"TELEGRAM_BOT_TOKEN,required"`
-	PostgresDSN string `env:"POSTGRES_DSN,required"`
-	LogLevel    string `env:"LOG_LEVEL" envDefault:"info"`
+	BotToken    string          `env:"TELEGRAM_BOT_TOKEN,required"`
+	PostgresDSN string          `env:"POSTGRES_DSN,required"`
+	LogLevel    string          `env:"LOG_LEVEL" envDefault:"info"`
+	LogFormat   string          `env:"LOG_FORMAT" envDefault:"json"`
+	BotMode     string          `env:"BOT_MODE" envDefault:"polling"`
+	Webhook     WebhookConfig   `envPrefix:"WEBHOOK_"`
+	RateLimit   RateLimitConfig `envPrefix:"RATE_LIMIT_"`
+	Access      AccessConfig
+	WebApp      WebAppConfig `envPrefix:"WEBAPP_"`
+	// APIListen is the address of the REST API server. Empty disables the API and /api_token.
+	APIListen string `env:"API_LISTEN"`
+	// MetricsListen is the address of the /metrics, /healthz and /readyz server. Empty disables it.
+	MetricsListen string `env:"METRICS_LISTEN"`
+	// StatsTopCategories is the number of categories listed in stats before the rest is summed up
+	// as "Прочее". Zero lists all of them.
+	StatsTopCategories int `env:"STATS_TOP_CATEGORIES" envDefault:"7"`
+}
+
+type WebhookConfig struct {
+	Listen      string `env:"LISTEN" envDefault:":8443"`
+	PublicURL   string `env:"PUBLIC_URL"`
+	SecretToken string `env:"SECRET_TOKEN"`
+	TLSCert     string `env:"TLS_CERT"`
+	TLSKey      string `env:"TLS_KEY"`
+}
+
+// WebAppConfig enables the Mini App dashboard. It is served on Listen and opened by Telegram from
+// PublicURL, which has to be HTTPS. An empty PublicURL disables the dashboard.
+type WebAppConfig struct {
+	Listen    string `env:"LISTEN" envDefault:":8081"`
+	PublicURL string `env:"PUBLIC_URL"`
+}
+
+// AccessConfig restricts the bot to a team. Admins may always use it and run the admin commands.
+// When AllowedIDs is empty the bot is open to everyone who is not blocked.
+type AccessConfig struct {
+	AdminIDs   []int64 `env:"ADMIN_IDS" envSeparator:","`
+	AllowedIDs []int64 `env:"ALLOWED_IDS" envSeparator:","`
+}
+
+// RateLimitConfig is the per-chat token bucket: a chat may send Burst updates at once and then
+// PerSecond updates a second. A zero PerSecond disables the limit.
+type RateLimitConfig struct {
+	PerSecond float64 `env:"PER_SECOND" envDefault:"1"`
+	Burst     int     `env:"BURST" envDefault:"20"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -17,5 +70,42 @@ func LoadConfig() (*Config, error) {
 	if err := env.Parse(cfg); err != nil {
 		return nil, fmt.Errorf("error parsing config: %w", err)
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("error validating config: %w", err)
+	}
 	return cfg, nil
 }
+
+func (c *Config) validate() error {
+	if c.RateLimit.PerSecond < 0 || c.RateLimit.Burst < 1 {
+		return errors.New("RATE_LIMIT_PER_SECOND must not be negative and RATE_LIMIT_BURST must be positive")
+	}
+	if c.StatsTopCategories < 0 {
+		return errors.New("STATS_TOP_CATEGORIES must not be negative")
+	}
+	if c.WebApp.PublicURL != "" && !strings.HasPrefix(c.WebApp.PublicURL, "https://") {
+		return errors.New("WEBAPP_PUBLIC_URL must be an https:// URL")
+	}
+	switch c.BotMode {
+	case "", BotModePolling:
+		c.BotMode = BotModePolling
+		return nil
+	case BotModeWebhook:
+		return c.Webhook.validate()
+	default:
+		return fmt.Errorf("unknown BOT_MODE %q", c.BotMode)
+	}
+}
+
+func (w *WebhookConfig) validate() error {
+	if w.PublicURL == "" {
+		return errors.New("WEBHOOK_PUBLIC_URL is required in webhook mode")
+	}
+	if !secretTokenPattern.MatchString(w.SecretToken) {
+		return errors.New("WEBHOOK_SECRET_TOKEN is required in webhook mode and may contain only A-Z, a-z, 0-9, _ and -")
+	}
+	if (w.TLSCert == "") != (w.TLSKey == "") {
+		return errors.New("WEBHOOK_TLS_CERT and WEBHOOK_TLS_KEY must be set together")
+	}
+	return nil
+}
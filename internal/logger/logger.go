@@ -4,7 +4,12 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func New(logLevel string) *logrus.Logger {
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+func New(logLevel, format string) *logrus.Logger {
 	log := logrus.New()
 	level, err := logrus.ParseLevel(logLevel)
 	if err != nil {
@@ -12,7 +17,17 @@ func New(logLevel string) *logrus.Logger {
 		log.Warn("unknown log level, use info")
 	}
 	log.SetLevel(level)
-	log.SetFormatter(&logrus.JSONFormatter{})
+
+	switch format {
+	case FormatText:
+		log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case FormatJSON, "":
+		log.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		log.SetFormatter(&logrus.JSONFormatter{})
+		log.Warnf("unknown log format %q, use json", format)
+	}
+	log.AddHook(redactHook{})
 
 	return log
 }
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fields carrying the user's financial data. They are logged only when the logger runs at debug level or
+// more verbose, otherwise their values are replaced with Redacted.
+const (
+	FieldAmount = "amount"
+	FieldNote   = "note"
+	FieldText   = "text"
+)
+
+const Redacted = "[redacted]"
+
+var sensitiveFields = []string{FieldAmount, FieldNote, FieldText}
+
+// redactHook applies the redaction policy. logrus fires hooks on a copy of the entry data,
+// so the caller's entry keeps its fields.
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	if entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		return nil
+	}
+	for _, field := range sensitiveFields {
+		if _, ok := entry.Data[field]; ok {
+			entry.Data[field] = Redacted
+		}
+	}
+	if err, ok := entry.Data[logrus.ErrorKey].(error); ok {
+		entry.Data[logrus.ErrorKey] = redactError(err)
+	}
+	return nil
+}
+
+// redactError hides the input of failed number parsing, which is usually an amount typed by the user or
+// taken from callback data. Other errors are kept as they are.
+func redactError(err error) error {
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) || numErr.Num == "" {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), strconv.Quote(numErr.Num), Redacted))
+}
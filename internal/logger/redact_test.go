@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRedactHook(t *testing.T) {
+	_, numErr := strconv.ParseFloat("350abc", 64)
+	tests := []struct {
+		name     string
+		level    logrus.Level
+		entry    func(log *logrus.Logger) *logrus.Entry
+		hidden   []string
+		revealed []string
+	}{
+		{
+			name:   "amount field",
+			level:  logrus.InfoLevel,
+			entry:  func(log *logrus.Logger) *logrus.Entry { return log.WithField(FieldAmount, 1234.5) },
+			hidden: []string{"1234.5"},
+		},
+		{
+			name:     "number parse error",
+			level:    logrus.InfoLevel,
+			entry:    func(log *logrus.Logger) *logrus.Entry { return log.WithError(numErr) },
+			hidden:   []string{"350abc"},
+			revealed: []string{"invalid syntax"},
+		},
+		{
+			name:  "wrapped number parse error",
+			level: logrus.InfoLevel,
+			entry: func(log *logrus.Logger) *logrus.Entry {
+				return log.WithError(fmt.Errorf("error handling transfer callback: %w", errors.Join(nil, numErr)))
+			},
+			hidden:   []string{"350abc"},
+			revealed: []string{"transfer callback"},
+		},
+		{
+			name:     "other error",
+			level:    logrus.InfoLevel,
+			entry:    func(log *logrus.Logger) *logrus.Entry { return log.WithError(errors.New("connection refused")) },
+			revealed: []string{"connection refused"},
+		},
+		{
+			name:     "debug level",
+			level:    logrus.DebugLevel,
+			entry:    func(log *logrus.Logger) *logrus.Entry { return log.WithError(numErr).WithField(FieldAmount, 1234.5) },
+			revealed: []string{"350abc", "1234.5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			log := New(tt.level.String(), FormatJSON)
+			log.SetOutput(&out)
+			tt.entry(log).Error("failed")
+			for _, s := range tt.hidden {
+				if strings.Contains(out.String(), s) {
+					t.Errorf("log contains %q: %s", s, out.String())
+				}
+			}
+			for _, s := range tt.revealed {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("log lacks %q: %s", s, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "budget_bot"
+
+var (
+	Updates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_total",
+		Help:      "Handled Telegram updates by type and command or callback action.",
+	}, []string{"type", "command"})
+
+	HandlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_duration_seconds",
+		Help:      "Duration of update handlers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler"})
+
+	HandlerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "handler_errors_total",
+		Help:      "Errors returned by update handlers.",
+	}, []string{"handler"})
+
+	HandlerPanics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "handler_panics_total",
+		Help:      "Panics recovered in update handlers.",
+	}, []string{"type"})
+
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_updates_total",
+		Help:      "Updates rejected by the per-chat rate limit.",
+	}, []string{"type"})
+
+	AccessDenied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "access_denied_updates_total",
+		Help:      "Updates from users who are blocked or not on the allowlist.",
+	}, []string{"type"})
+
+	StorageQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_query_duration_seconds",
+		Help:      "Duration of storage calls by Storage method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method"})
+)
+
+func init() {
+	prometheus.MustRegister(Updates, HandlerDuration, HandlerErrors, HandlerPanics, RateLimited, AccessDenied,
+		StorageQueryDuration)
+}
+
+// RegisterActiveSessions exposes the number of users in the middle of a dialog.
+func RegisterActiveSessions(count func() int) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Users in the middle of a multi-step dialog.",
+	}, func() float64 { return float64(count()) }))
+}
+
+// RegisterPool exposes the statistics of the database connection pool.
+func RegisterPool(stat func() *pgxpool.Stat) {
+	prometheus.MustRegister(&poolCollector{stat: stat})
+}
+
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+}
+
+var (
+	poolAcquiredConns = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pgxpool", "acquired_conns"), "Connections currently in use.", nil, nil)
+	poolIdleConns = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pgxpool", "idle_conns"), "Idle connections.", nil, nil)
+	poolTotalConns = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pgxpool", "total_conns"), "Total connections.", nil, nil)
+	poolMaxConns = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pgxpool", "max_conns"), "Maximum pool size.", nil, nil)
+	poolAcquireCount = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pgxpool", "acquire_total"), "Successful connection acquires.", nil, nil)
+	poolAcquireDuration = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pgxpool", "acquire_duration_seconds_total"),
+		"Total time spent acquiring connections.", nil, nil)
+	poolEmptyAcquireCount = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pgxpool", "empty_acquire_total"),
+		"Acquires that had to wait for a connection.", nil, nil)
+	poolCanceledAcquireCount = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pgxpool", "canceled_acquire_total"),
+		"Acquires canceled by their context.", nil, nil)
+)
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredConns
+	ch <- poolIdleConns
+	ch <- poolTotalConns
+	ch <- poolMaxConns
+	ch <- poolAcquireCount
+	ch <- poolAcquireDuration
+	ch <- poolEmptyAcquireCount
+	ch <- poolCanceledAcquireCount
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireCount, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDuration, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquireCount, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(
+		poolCanceledAcquireCount, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+}
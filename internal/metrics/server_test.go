@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestReadyz(t *testing.T) {
+	log := &logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Level: logrus.PanicLevel}
+	tests := []struct {
+		name    string
+		pingErr error
+		want    int
+	}{
+		{"database up", nil, http.StatusOK},
+		{"database down", errors.New("dial tcp 10.0.0.5:5432: connect: connection refused"), http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(":0", func(context.Context) error { return tt.pingErr }, log)
+			w := httptest.NewRecorder()
+			server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if strings.Contains(w.Body.String(), "10.0.0.5") {
+				t.Errorf("body leaks the error: %q", w.Body)
+			}
+		})
+	}
+}
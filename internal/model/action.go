@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Kinds of journaled actions, see Action.
+const (
+	ActionAddTransaction = 1
+	ActionRenameCategory = 2
+)
+
+// Action is an entry of the journal of user actions that can be undone.
+type Action struct {
+	ID        int64
+	ChatID    int64
+	Kind      uint8
+	TargetID  int64   // transaction or category
+	OldValue  string  // previous name of a renamed category
+	Amount    float64 // amount of an undone transaction, filled by the undo
+	CreatedAt time.Time
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// AdminStats is the overview shown by /admin_stats.
+type AdminStats struct {
+	Users              int
+	BlockedUsers       int
+	TransactionsPerDay []DailyCount
+}
+
+type DailyCount struct {
+	Day   time.Time
+	Count int
+}
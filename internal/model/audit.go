@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Audited entities and operations, see AuditEntry.
+const (
+	AuditEntityCategory    = "category"
+	AuditEntityTransaction = "transaction"
+
+	AuditOperationInsert = "insert"
+	AuditOperationUpdate = "update"
+	AuditOperationDelete = "delete"
+
+	// Sources of changes: the bot, the Mini App dashboard and the REST API.
+	AuditSourceBot    = "bot"
+	AuditSourceWebApp = "webapp"
+	AuditSourceAPI    = "api"
+)
+
+// AuditEntry is a change of a category or a transaction. Before and After are the rows as JSON objects,
+// Before is nil for an insert and After for a delete. Source tells where the actor made the change.
+type AuditEntry struct {
+	ID        int64
+	ChatID    int64
+	ActorID   int64
+	Source    string
+	Entity    string
+	EntityID  int64
+	Operation string
+	Before    map[string]any
+	After     map[string]any
+	CreatedAt time.Time
+}
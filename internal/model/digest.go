@@ -0,0 +1,69 @@
+package model
+
+import "time"
+
+type DigestSettings struct {
+	ChatID     int64
+	Period     uint8
+	SendMinute int // local time of day, minutes after midnight
+	Timezone   string
+	LastSentAt time.Time // scheduled moment of the last digest sent
+}
+
+// periodStart returns local midnight of the day, Monday or first day of the month containing t.
+func (d *DigestSettings) periodStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch d.Period {
+	case DigestPeriodWeekly:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case DigestPeriodMonthly:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+func (d *DigestSettings) addPeriods(start time.Time, n int) time.Time {
+	switch d.Period {
+	case DigestPeriodWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case DigestPeriodMonthly:
+		return start.AddDate(0, n, 0)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
+
+func (d *DigestSettings) dueIn(periodStart time.Time) time.Time {
+	return time.Date(
+		periodStart.Year(), periodStart.Month(), periodStart.Day(),
+		d.SendMinute/60, d.SendMinute%60, 0, 0, periodStart.Location(),
+	)
+}
+
+// LastDue returns the latest scheduled moment not after now.
+func (d *DigestSettings) LastDue(now time.Time) time.Time {
+	now = now.In(LoadLocation(d.Timezone))
+	start := d.periodStart(now)
+	if due := d.dueIn(start); !due.After(now) {
+		return due
+	}
+	return d.dueIn(d.addPeriods(start, -1))
+}
+
+// NextDue returns the first scheduled moment after t.
+func (d *DigestSettings) NextDue(t time.Time) time.Time {
+	t = t.In(LoadLocation(d.Timezone))
+	start := d.periodStart(t)
+	if due := d.dueIn(start); due.After(t) {
+		return due
+	}
+	return d.dueIn(d.addPeriods(start, 1))
+}
+
+// Range returns the period [start, end) summarized by the digest scheduled at due: the previous day,
+// week or month.
+func (d *DigestSettings) Range(due time.Time) (time.Time, time.Time) {
+	end := d.periodStart(due.In(LoadLocation(d.Timezone)))
+	return d.addPeriods(end, -1), end
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+const averageDaysInMonth = 30.44
+
+// Progress returns the saved share of the target, capped at 1.
+func (g *Goal) Progress() float64 {
+	if g.TargetAmount <= 0 {
+		return 0
+	}
+	return math.Min(g.Saved/g.TargetAmount, 1)
+}
+
+// Remaining returns the amount still to save, never negative.
+func (g *Goal) Remaining() float64 {
+	return math.Max(g.TargetAmount-g.Saved, 0)
+}
+
+// RequiredMonthlyPace returns how much has to be saved per month from now on to reach the target by
+// the deadline. Less than a month left counts as one month.
+func (g *Goal) RequiredMonthlyPace(now time.Time) float64 {
+	return g.Remaining() / math.Max(monthsBetween(now, g.Deadline), 1)
+}
+
+// CurrentMonthlyPace returns the average saved per month since the goal was created.
+// The first month counts as a whole one so that a fresh goal is not judged by a few days.
+func (g *Goal) CurrentMonthlyPace(now time.Time) float64 {
+	return g.Saved / math.Max(monthsBetween(g.CreatedAt, now), 1)
+}
+
+// IsBehind reports whether saving at the current pace would miss the deadline.
+func (g *Goal) IsBehind(now time.Time) bool {
+	return g.Remaining() > 0 && g.CurrentMonthlyPace(now) < g.RequiredMonthlyPace(now)
+}
+
+func monthsBetween(from, to time.Time) float64 {
+	return to.Sub(from).Hours() / 24 / averageDaysInMonth
+}
@@ -6,17 +6,55 @@ const (
 	StateAwaitingNewCategoryName UserState = iota + 1
 	StateAwaitingRenameCategory
 	StateAwaitingPeriod
+	StateAwaitingCategoryIcon
+	StateAwaitingSearchQuery
+	StateAwaitingAccountName
+	StateAwaitingAccountBalance
+	StateAwaitingGoalName
+	StateAwaitingGoalTarget
+	StateAwaitingGoalDeadline
+	StateAwaitingDebtCounterparty
+	StateAwaitingDebtAmount
+	StateAwaitingDebtDueDate
+	StateAwaitingDebtRepayment
+	StateAwaitingDigestTime
+	StateAwaitingTimezone
+	StateAwaitingReminderTime
+	StateAwaitingQuickAmount
+	StateAwaitingTemplateName
 )
 
 const (
-	TransactionTypeIncome  uint8 = 1
-	TransactionTypeExpense uint8 = 2
+	TransactionTypeIncome   uint8 = 1
+	TransactionTypeExpense  uint8 = 2
+	TransactionTypeTransfer uint8 = 3
+)
+
+const (
+	DebtDirectionLent     uint8 = 1 // the counterparty owes the user
+	DebtDirectionBorrowed uint8 = 2 // the user owes the counterparty
+)
+
+const (
+	CategoryKindBoth    uint8 = 0
+	CategoryKindIncome  uint8 = 1
+	CategoryKindExpense uint8 = 2
+)
+
+const DefaultTimezone = "Europe/Moscow"
+
+const (
+	DigestPeriodOff     uint8 = 0
+	DigestPeriodDaily   uint8 = 1
+	DigestPeriodWeekly  uint8 = 2
+	DigestPeriodMonthly uint8 = 3
 )
 
 type User struct {
 	Username  string
 	ChatID    int64
 	Language  string
+	Timezone  string
 	CreatedAt time.Time
 }
 
@@ -24,27 +62,129 @@ type Category struct {
 	ID        int64
 	Name      string
 	ChatID    int64
+	ParentID  int64 // 0 for top-level categories
+	Kind      uint8
+	Icon      string
+	SortOrder int
 	CreatedAt time.Time
 }
 
+type Account struct {
+	ID             int64
+	ChatID         int64
+	Name           string
+	OpeningBalance float64
+	CreatedAt      time.Time
+}
+
+type AccountBalance struct {
+	Account Account
+	Balance float64
+}
+
+type Goal struct {
+	ID           int64
+	ChatID       int64
+	Name         string
+	TargetAmount float64
+	Deadline     time.Time
+	Saved        float64 // sum of contributions, filled when reading goals
+	CreatedAt    time.Time
+}
+
+type Debt struct {
+	ID           int64
+	ChatID       int64
+	Counterparty string
+	Direction    uint8
+	Amount       float64
+	Repaid       float64   // sum of repayments, filled when reading debts
+	DueDate      time.Time // zero when there is no due date
+	CreatedAt    time.Time
+}
+
 type Transaction struct {
+	ID              int64
 	ChatID          int64
-	CategoryID      int64
+	CategoryID      int64 // 0 for transfers
+	AccountID       int64
+	ToAccountID     int64 // destination of a transfer, 0 otherwise
 	Amount          float64
 	TransactionType uint8
 	CreatedAt       time.Time
 }
 
+// APIToken describes the personal access token of a chat. The token itself is never stored, only its hash,
+// which is not read back.
+type APIToken struct {
+	ChatID     int64
+	CreatedAt  time.Time
+	LastUsedAt time.Time // zero until the token is used
+}
+
+// TransactionFilter selects transactions. Zero fields do not filter.
+type TransactionFilter struct {
+	From            time.Time // inclusive
+	To              time.Time // exclusive
+	TransactionType uint8
+	CategoryID      int64
+	AccountID       int64 // source or destination account
+	Limit           int
+	Offset          int
+}
+
 type UserState int
 
 type UserSession struct {
 	State             UserState
 	CategoryID        int
+	ParentCategoryID  int64
 	TransactionAmount float64
 	StartDate         time.Time
 	EndDate           time.Time
+	SearchRoute       []string
+	AccountName       string
+	GoalName          string
+	GoalTarget        float64
+	Debt              Debt
+	DigestPeriod      uint8
+	TransactionID     int64
 }
 
 func (u *User) IsEmpty() bool {
 	return u.ChatID == 0 && u.CreatedAt.IsZero()
 }
+
+// Location returns the time zone of the user, falling back to DefaultTimezone.
+func (u *User) Location() *time.Location {
+	return LoadLocation(u.Timezone)
+}
+
+// LoadLocation loads a time zone by name, falling back to DefaultTimezone and then to UTC.
+func LoadLocation(name string) *time.Location {
+	if loc, err := time.LoadLocation(name); err == nil && name != "" {
+		return loc
+	}
+	if loc, err := time.LoadLocation(DefaultTimezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// AllowsTransactionType reports whether transactions of the given type may be booked to the category.
+func (c *Category) AllowsTransactionType(transactionType uint8) bool {
+	return c.Kind == CategoryKindBoth || c.Kind == transactionType
+}
+
+// Label returns the category name prefixed with its icon, if any.
+func (c *Category) Label() string {
+	if c.Icon == "" {
+		return c.Name
+	}
+	return c.Icon + " " + c.Name
+}
+
+// Remaining returns the part of the debt not repaid yet.
+func (d *Debt) Remaining() float64 {
+	return d.Amount - d.Repaid
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operation types of a fiscal receipt, the n field of its QR code.
+const (
+	ReceiptTypeSale           = 1 // приход, a purchase
+	ReceiptTypeSaleRefund     = 2 // возврат прихода
+	ReceiptTypePurchase       = 3 // расход, the seller pays, e.g. a buyback
+	ReceiptTypePurchaseRefund = 4 // возврат расхода
+)
+
+var ErrReceiptInvalid = errors.New("not a fiscal receipt QR code")
+
+// receiptTimeLayouts are the formats of the t field, with and without seconds.
+var receiptTimeLayouts = []string{"20060102T150405", "20060102T1504"}
+
+// Receipt is the content of the QR code printed on Russian fiscal receipts, e.g.
+// "t=20240315T1423&s=1234.56&fn=9960440300000000&i=12345&fp=1234567890&n=1".
+type Receipt struct {
+	Time        time.Time
+	Total       float64
+	Type        uint8
+	FiscalDrive string // fn
+	Document    string // i
+	FiscalSign  string // fp
+}
+
+// ParseReceipt parses the text of a receipt QR code. The receipt time carries no zone, it is the local time
+// of the purchase and is read in loc.
+func ParseReceipt(text string, loc *time.Location) (Receipt, error) {
+	values, err := url.ParseQuery(strings.TrimSpace(text))
+	if err != nil || !values.Has("t") || !values.Has("s") {
+		return Receipt{}, ErrReceiptInvalid
+	}
+
+	r := Receipt{
+		Type:        ReceiptTypeSale,
+		FiscalDrive: values.Get("fn"),
+		Document:    values.Get("i"),
+		FiscalSign:  values.Get("fp"),
+	}
+
+	for _, layout := range receiptTimeLayouts {
+		if r.Time, err = time.ParseInLocation(layout, values.Get("t"), loc); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return Receipt{}, ErrReceiptInvalid
+	}
+
+	r.Total, err = strconv.ParseFloat(strings.ReplaceAll(values.Get("s"), ",", "."), 64)
+	if err != nil || r.Total <= 0 {
+		return Receipt{}, ErrReceiptInvalid
+	}
+
+	if values.Has("n") {
+		n, err := strconv.ParseUint(values.Get("n"), 10, 8)
+		if err != nil || n < ReceiptTypeSale || n > ReceiptTypePurchaseRefund {
+			return Receipt{}, ErrReceiptInvalid
+		}
+		r.Type = uint8(n)
+	}
+	return r, nil
+}
+
+// TransactionType is the transaction the receipt means for the buyer: money leaves on a purchase and
+// comes back on its refund.
+func (r Receipt) TransactionType() uint8 {
+	switch r.Type {
+	case ReceiptTypeSaleRefund, ReceiptTypePurchase:
+		return TransactionTypeIncome
+	default:
+		return TransactionTypeExpense
+	}
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseReceipt(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*60*60)
+	tests := []struct {
+		name    string
+		text    string
+		want    Receipt
+		wantErr bool
+	}{
+		{
+			name: "purchase",
+			text: "t=20240315T142305&s=1234.56&fn=9960440300000000&i=12345&fp=1234567890&n=1",
+			want: Receipt{
+				Time:        time.Date(2024, 3, 15, 14, 23, 5, 0, loc),
+				Total:       1234.56,
+				Type:        ReceiptTypeSale,
+				FiscalDrive: "9960440300000000",
+				Document:    "12345",
+				FiscalSign:  "1234567890",
+			},
+		},
+		{
+			name: "time without seconds",
+			text: "t=20240315T1423&s=99.90&fn=9960440300000000&i=7&fp=42&n=1",
+			want: Receipt{
+				Time:        time.Date(2024, 3, 15, 14, 23, 0, 0, loc),
+				Total:       99.9,
+				Type:        ReceiptTypeSale,
+				FiscalDrive: "9960440300000000",
+				Document:    "7",
+				FiscalSign:  "42",
+			},
+		},
+		{
+			name: "reordered fields and surrounding whitespace",
+			text: "  n=1&fp=42&i=7&s=500.00&t=20240101T0000&fn=1  ",
+			want: Receipt{
+				Time:        time.Date(2024, 1, 1, 0, 0, 0, 0, loc),
+				Total:       500,
+				Type:        ReceiptTypeSale,
+				FiscalDrive: "1",
+				Document:    "7",
+				FiscalSign:  "42",
+			},
+		},
+		{
+			name: "missing n means a purchase",
+			text: "t=20240315T1423&s=10",
+			want: Receipt{Time: time.Date(2024, 3, 15, 14, 23, 0, 0, loc), Total: 10, Type: ReceiptTypeSale},
+		},
+		{
+			name: "refund",
+			text: "t=20240315T1423&s=10&n=2",
+			want: Receipt{Time: time.Date(2024, 3, 15, 14, 23, 0, 0, loc), Total: 10, Type: ReceiptTypeSaleRefund},
+		},
+		{
+			name: "buyback",
+			text: "t=20240315T1423&s=10&n=3",
+			want: Receipt{Time: time.Date(2024, 3, 15, 14, 23, 0, 0, loc), Total: 10, Type: ReceiptTypePurchase},
+		},
+		{
+			name: "buyback refund",
+			text: "t=20240315T1423&s=10&n=4",
+			want: Receipt{Time: time.Date(2024, 3, 15, 14, 23, 0, 0, loc), Total: 10, Type: ReceiptTypePurchaseRefund},
+		},
+		{
+			name: "comma decimal separator",
+			text: "t=20240315T1423&s=10,50&n=1",
+			want: Receipt{Time: time.Date(2024, 3, 15, 14, 23, 0, 0, loc), Total: 10.5, Type: ReceiptTypeSale},
+		},
+		{name: "unknown kind", text: "t=20240315T1423&s=10&n=5", wantErr: true},
+		{name: "zero kind", text: "t=20240315T1423&s=10&n=0", wantErr: true},
+		{name: "non-numeric kind", text: "t=20240315T1423&s=10&n=x", wantErr: true},
+		{name: "missing total", text: "t=20240315T1423&fn=1&i=2&fp=3&n=1", wantErr: true},
+		{name: "zero total", text: "t=20240315T1423&s=0&n=1", wantErr: true},
+		{name: "negative total", text: "t=20240315T1423&s=-5&n=1", wantErr: true},
+		{name: "non-numeric total", text: "t=20240315T1423&s=abc&n=1", wantErr: true},
+		{name: "missing time", text: "s=10&n=1", wantErr: true},
+		{name: "malformed time", text: "t=2024-03-15 14:23&s=10&n=1", wantErr: true},
+		{name: "date only", text: "t=20240315&s=10&n=1", wantErr: true},
+		{name: "impossible date", text: "t=20241315T1423&s=10&n=1", wantErr: true},
+		{name: "not a receipt", text: "https://example.com/?q=1", wantErr: true},
+		{name: "empty", text: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReceipt(tt.text, loc)
+			if tt.wantErr {
+				if !errors.Is(err, ErrReceiptInvalid) {
+					t.Errorf("ParseReceipt() error = %v, want ErrReceiptInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseReceipt() error = %v", err)
+			}
+			if !got.Time.Equal(tt.want.Time) || got.Time.Location() != loc {
+				t.Errorf("Time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time, tt.want.Time = time.Time{}, time.Time{}
+			if got != tt.want {
+				t.Errorf("ParseReceipt() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReceiptTransactionType(t *testing.T) {
+	tests := []struct {
+		receiptType uint8
+		want        uint8
+	}{
+		{ReceiptTypeSale, TransactionTypeExpense},
+		{ReceiptTypeSaleRefund, TransactionTypeIncome},
+		{ReceiptTypePurchase, TransactionTypeIncome},
+		{ReceiptTypePurchaseRefund, TransactionTypeExpense},
+	}
+	for _, tt := range tests {
+		if got := (Receipt{Type: tt.receiptType}).TransactionType(); got != tt.want {
+			t.Errorf("Receipt{Type: %d}.TransactionType() = %d, want %d", tt.receiptType, got, tt.want)
+		}
+	}
+}
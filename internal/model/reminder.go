@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+type ReminderSettings struct {
+	ChatID       int64
+	SendMinute   int // local time of day, minutes after midnight
+	Timezone     string
+	SnoozedUntil time.Time // zero when not snoozed
+	LastSentOn   time.Time // local date of the last reminder, zero when none was sent
+}
+
+// IsDue reports whether the reminder should be sent at now: the local send time has passed, no reminder was
+// sent today and the reminder is not snoozed.
+func (r *ReminderSettings) IsDue(now time.Time) bool {
+	local := now.In(LoadLocation(r.Timezone))
+	if local.Hour()*60+local.Minute() < r.SendMinute {
+		return false
+	}
+	if now.Before(r.SnoozedUntil) {
+		return false
+	}
+	y, m, d := local.Date()
+	sy, sm, sd := r.LastSentOn.Date()
+	return r.LastSentOn.IsZero() || y != sy || m != sm || d != sd
+}
+
+// Today returns the bounds [start, end) of the local day containing now.
+func (r *ReminderSettings) Today(now time.Time) (time.Time, time.Time) {
+	local := now.In(LoadLocation(r.Timezone))
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	return start, start.AddDate(0, 0, 1)
+}
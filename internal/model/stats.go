@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// DailyAmount is the sum of transactions booked on one calendar day.
+type DailyAmount struct {
+	Day    time.Time
+	Amount float64
+}
+
+// CategoryStat is the total of one category's transactions of one type over a period.
+type CategoryStat struct {
+	CategoryID      int64
+	Name            string
+	TransactionType uint8
+	Sum             float64
+	Count           int
+}
@@ -0,0 +1,24 @@
+package model
+
+import "strconv"
+
+// Template is a transaction saved under a name to be booked again in one tap.
+type Template struct {
+	ID              int64
+	ChatID          int64
+	Name            string
+	CategoryID      int64
+	AccountID       int64
+	Amount          float64
+	TransactionType uint8
+	CategoryIcon    string // icon of the category, filled when reading templates
+}
+
+// Label is the template as shown on buttons, e.g. "☕ Кофе 250".
+func (t *Template) Label() string {
+	label := t.Name + " " + strconv.FormatFloat(t.Amount, 'f', -1, 64)
+	if t.CategoryIcon == "" {
+		return label
+	}
+	return t.CategoryIcon + " " + label
+}
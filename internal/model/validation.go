@@ -0,0 +1,145 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	NameMaxLength         = 50
+	CategoryIconMaxLength = 8
+)
+
+var (
+	ErrNameEmpty           = errors.New("name is empty")
+	ErrNameTooLong         = errors.New("name is too long")
+	ErrNameInvalidChars    = errors.New("name contains invalid characters")
+	ErrCategoryIconInvalid = errors.New("category icon must be a single emoji")
+	ErrAmountNotPositive   = errors.New("amount must be positive")
+	// The errors of ValidateTransaction.
+	ErrTransactionTypeInvalid = errors.New("transaction type must be income or expense")
+	ErrUnknownCategory        = errors.New("unknown category")
+	ErrCategoryNotAllowed     = errors.New("category does not allow the transaction type")
+	ErrUnknownAccount         = errors.New("unknown account")
+	ErrTransferSameAccount    = errors.New("transfer to the same account")
+)
+
+// namePunctuation lists the punctuation allowed in names besides letters and digits.
+// Markdown control characters (*, _, `, [, ]) are deliberately excluded.
+const namePunctuation = " -.,()&+'/№%"
+
+// NormalizeCategoryName trims the name, collapses inner whitespace and validates its length and characters.
+func NormalizeCategoryName(name string) (string, error) {
+	return NormalizeName(name)
+}
+
+// NormalizeName applies the category name rules to the names of accounts, goals and other entities.
+func NormalizeName(name string) (string, error) {
+	normalized := strings.Join(strings.Fields(name), " ")
+	if normalized == "" {
+		return "", ErrNameEmpty
+	}
+	if utf8.RuneCountInString(normalized) > NameMaxLength {
+		return "", ErrNameTooLong
+	}
+	for _, r := range normalized {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(namePunctuation, r) {
+			continue
+		}
+		return "", ErrNameInvalidChars
+	}
+	return normalized, nil
+}
+
+// NormalizeCategoryIcon validates an emoji icon. A dash or an empty string clears the icon.
+func NormalizeCategoryIcon(icon string) (string, error) {
+	icon = strings.TrimSpace(icon)
+	if icon == "" || icon == "-" {
+		return "", nil
+	}
+	if utf8.RuneCountInString(icon) > CategoryIconMaxLength {
+		return "", ErrCategoryIconInvalid
+	}
+	for _, r := range icon {
+		if unicode.Is(unicode.So, r) || unicode.Is(unicode.Sk, r) || unicode.Is(unicode.Mn, r) ||
+			unicode.Is(unicode.Me, r) || r == '\u200d' || r == '\ufe0f' {
+			continue
+		}
+		return "", ErrCategoryIconInvalid
+	}
+	return icon, nil
+}
+
+// ParseAmount parses a positive amount typed by the user. A comma is accepted as the decimal separator.
+func ParseAmount(text string) (float64, error) {
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(text), ",", "."), 64)
+	if err != nil {
+		return 0, err
+	}
+	if !ValidAmount(amount) {
+		return 0, ErrAmountNotPositive
+	}
+	return amount, nil
+}
+
+// ValidDebtDirection reports whether direction is DebtDirectionLent or DebtDirectionBorrowed.
+func ValidDebtDirection(direction uint8) bool {
+	return direction == DebtDirectionLent || direction == DebtDirectionBorrowed
+}
+
+// ValidAmount reports whether amount may be booked: positive and finite.
+func ValidAmount(amount float64) bool {
+	return amount > 0 && !math.IsInf(amount, 1)
+}
+
+// ValidateTransaction checks an income or an expense before it is booked or changed: the amount has to be
+// positive, the category and the account have to belong to the chat of the transaction and the category has
+// to allow the type. category is the category the transaction is booked to, nil when it was not found, and
+// accounts are the accounts of the chat.
+func ValidateTransaction(transaction Transaction, category *Category, accounts []Account) error {
+	if transaction.TransactionType != TransactionTypeIncome && transaction.TransactionType != TransactionTypeExpense {
+		return ErrTransactionTypeInvalid
+	}
+	if !ValidAmount(transaction.Amount) {
+		return ErrAmountNotPositive
+	}
+	if category == nil || category.ID != transaction.CategoryID || category.ChatID != transaction.ChatID {
+		return ErrUnknownCategory
+	}
+	if !category.AllowsTransactionType(transaction.TransactionType) {
+		return ErrCategoryNotAllowed
+	}
+	for _, a := range accounts {
+		if a.ID == transaction.AccountID && a.ChatID == transaction.ChatID {
+			return nil
+		}
+	}
+	return ErrUnknownAccount
+}
+
+// ValidateTransfer checks a transfer before it is booked: the amount has to be positive and both accounts,
+// which have to differ, have to be among accounts, the accounts of the chat of the transfer.
+func ValidateTransfer(transfer Transaction, accounts []Account) error {
+	if !ValidAmount(transfer.Amount) {
+		return ErrAmountNotPositive
+	}
+	if transfer.AccountID == transfer.ToAccountID {
+		return ErrTransferSameAccount
+	}
+	var from, to bool
+	for _, a := range accounts {
+		if a.ChatID != transfer.ChatID {
+			continue
+		}
+		from = from || a.ID == transfer.AccountID
+		to = to || a.ID == transfer.ToAccountID
+	}
+	if !from || !to {
+		return ErrUnknownAccount
+	}
+	return nil
+}
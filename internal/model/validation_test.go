@@ -0,0 +1,112 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateTransaction(t *testing.T) {
+	const chatID, otherChatID = 1, 2
+	expenseCategory := &Category{ID: 10, ChatID: chatID, Kind: CategoryKindExpense}
+	incomeCategory := &Category{ID: 11, ChatID: chatID, Kind: CategoryKindIncome}
+	bothCategory := &Category{ID: 12, ChatID: chatID, Kind: CategoryKindBoth}
+	foreignCategory := &Category{ID: 20, ChatID: otherChatID, Kind: CategoryKindBoth}
+	accounts := []Account{{ID: 100, ChatID: chatID}, {ID: 101, ChatID: chatID}}
+	foreignAccounts := []Account{{ID: 200, ChatID: otherChatID}}
+
+	transaction := func(transactionType uint8, categoryID, accountID int64, amount float64) Transaction {
+		return Transaction{
+			ChatID:          chatID,
+			CategoryID:      categoryID,
+			AccountID:       accountID,
+			Amount:          amount,
+			TransactionType: transactionType,
+		}
+	}
+	expense := func(categoryID, accountID int64, amount float64) Transaction {
+		return transaction(TransactionTypeExpense, categoryID, accountID, amount)
+	}
+
+	tests := []struct {
+		name        string
+		transaction Transaction
+		category    *Category
+		accounts    []Account
+		want        error
+	}{
+		{"expense", expense(10, 100, 350), expenseCategory, accounts, nil},
+		{"income", transaction(TransactionTypeIncome, 11, 100, 500), incomeCategory, accounts, nil},
+		{"second account", expense(10, 101, 1), expenseCategory, accounts, nil},
+		{"category of both kinds", expense(12, 100, 1), bothCategory, accounts, nil},
+		{"expense in income category", expense(11, 100, 1), incomeCategory, accounts, ErrCategoryNotAllowed},
+		{"income in expense category", transaction(TransactionTypeIncome, 10, 100, 1), expenseCategory, accounts,
+			ErrCategoryNotAllowed},
+		{"transfer", transaction(TransactionTypeTransfer, 0, 100, 5), nil, accounts, ErrTransactionTypeInvalid},
+		{"zero amount", expense(10, 100, 0), expenseCategory, accounts, ErrAmountNotPositive},
+		{"negative amount", expense(10, 100, -5), expenseCategory, accounts, ErrAmountNotPositive},
+		{"missing category", expense(10, 100, 1), nil, accounts, ErrUnknownCategory},
+		{"foreign category", expense(20, 100, 1), foreignCategory, accounts, ErrUnknownCategory},
+		{"category of other ID", expense(13, 100, 1), expenseCategory, accounts, ErrUnknownCategory},
+		{"foreign account", expense(10, 200, 1), expenseCategory, foreignAccounts, ErrUnknownAccount},
+		{"unknown account", expense(10, 999, 1), expenseCategory, accounts, ErrUnknownAccount},
+		{"no accounts", expense(10, 100, 1), expenseCategory, nil, ErrUnknownAccount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTransaction(tt.transaction, tt.category, tt.accounts)
+			if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Errorf("ValidateTransaction() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTransfer(t *testing.T) {
+	const chatID = 1
+	accounts := []Account{{ID: 100, ChatID: chatID}, {ID: 101, ChatID: chatID}, {ID: 200, ChatID: 2}}
+	transfer := func(from, to int64, amount float64) Transaction {
+		return Transaction{
+			ChatID:          chatID,
+			AccountID:       from,
+			ToAccountID:     to,
+			Amount:          amount,
+			TransactionType: TransactionTypeTransfer,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		transfer Transaction
+		want     error
+	}{
+		{"own accounts", transfer(100, 101, 50), nil},
+		{"back", transfer(101, 100, 0.5), nil},
+		{"same account", transfer(100, 100, 50), ErrTransferSameAccount},
+		{"from a foreign account", transfer(200, 100, 50), ErrUnknownAccount},
+		{"to a foreign account", transfer(100, 200, 50), ErrUnknownAccount},
+		{"unknown account", transfer(100, 999, 50), ErrUnknownAccount},
+		{"zero amount", transfer(100, 101, 0), ErrAmountNotPositive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTransfer(tt.transfer, accounts)
+			if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Errorf("ValidateTransfer() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidDebtDirection(t *testing.T) {
+	for direction, want := range map[uint8]bool{
+		0:                     false,
+		DebtDirectionLent:     true,
+		DebtDirectionBorrowed: true,
+		3:                     false,
+		255:                   false,
+	} {
+		if got := ValidDebtDirection(direction); got != want {
+			t.Errorf("ValidDebtDirection(%d) = %v, want %v", direction, got, want)
+		}
+	}
+}
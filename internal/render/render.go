@@ -0,0 +1,91 @@
+// Package render builds Telegram messages in HTML parse mode. Text added through a Message is escaped,
+// so category names and other user input can never break the markup, and long messages are split into
+// parts that fit into a single Telegram message.
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseMode is the Telegram parse mode of the rendered messages.
+const ParseMode = "HTML"
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Escape makes s safe to put into an HTML message as plain text.
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// Message is an HTML message under construction. Formatting tags never span a line break, which lets
+// Split cut the message between lines without breaking the markup. The zero value is an empty message.
+type Message struct {
+	sb strings.Builder
+}
+
+func New() *Message {
+	return &Message{}
+}
+
+// Text appends plain text.
+func (m *Message) Text(s string) *Message {
+	m.sb.WriteString(Escape(s))
+	return m
+}
+
+// Textf appends plain text formatted with fmt.Sprintf.
+func (m *Message) Textf(format string, args ...any) *Message {
+	return m.Text(fmt.Sprintf(format, args...))
+}
+
+// Bold appends bold text.
+func (m *Message) Bold(s string) *Message {
+	return m.tag("b", s)
+}
+
+// Boldf appends bold text formatted with fmt.Sprintf.
+func (m *Message) Boldf(format string, args ...any) *Message {
+	return m.Bold(fmt.Sprintf(format, args...))
+}
+
+// Code appends monospace text, which Telegram copies on tap.
+func (m *Message) Code(s string) *Message {
+	return m.tag("code", s)
+}
+
+// Line appends a line break.
+func (m *Message) Line() *Message {
+	m.sb.WriteByte('\n')
+	return m
+}
+
+// Append appends another message.
+func (m *Message) Append(other *Message) *Message {
+	m.sb.WriteString(other.String())
+	return m
+}
+
+// String returns the HTML of the message.
+func (m *Message) String() string {
+	return m.sb.String()
+}
+
+// Parts returns the message split into parts of at most MaxLength characters.
+func (m *Message) Parts() []string {
+	return Split(m.String(), MaxLength)
+}
+
+// tag wraps every line of s in the tag separately, so that the tag is closed before each line break.
+func (m *Message) tag(name, s string) *Message {
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			m.sb.WriteByte('\n')
+		}
+		if line == "" {
+			continue
+		}
+		m.sb.WriteString("<" + name + ">" + Escape(line) + "</" + name + ">")
+	}
+	return m
+}
@@ -0,0 +1,70 @@
+package render
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with testdata/<name>.golden, or rewrites the file when the tests run with -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s does not match:\ngot\n%s\nwant\n%s", path, got, want)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Еда", "Еда"},
+		{"Кафе & бары", "Кафе &amp; бары"},
+		{"<b>Такси</b>", "&lt;b&gt;Такси&lt;/b&gt;"},
+		{"&amp;", "&amp;amp;"},
+		{"1 < 2 > 0", "1 &lt; 2 &gt; 0"},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.in); got != tt.want {
+			t.Errorf("Escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	other := New().Text("🔮 ").Boldf("Прогноз на %s", "июнь").Textf(": %.1f", 300.0)
+
+	msg := New().
+		Text("📊 ").Bold("Статистика за период").Line().Line().
+		Textf("  - %s: %.1f", "Кафе & <бары>", 50.0).Line().
+		Text("Категория ").Bold("<script>").Text(" создана").Line().
+		Bold("Первая строка\nвторая строка\n\nпосле пустой").Line().
+		Text("Токен: ").Code("a&b<c>").Line().
+		Boldf("%d%% & больше", 40).Line().
+		Append(other)
+	golden(t, "message", msg.String())
+}
+
+func TestZeroMessage(t *testing.T) {
+	var msg Message
+	if got := msg.Text("Еда").String(); got != "Еда" {
+		t.Errorf("zero Message = %q, want %q", got, "Еда")
+	}
+	if parts := New().Parts(); len(parts) != 1 || parts[0] != "" {
+		t.Errorf("New().Parts() = %q, want one empty part", parts)
+	}
+}
@@ -0,0 +1,138 @@
+package render
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxLength is the longest text Telegram accepts in one message. Telegram counts UTF-16 code units.
+const MaxLength = 4096
+
+// Split cuts an HTML message into parts of at most limit UTF-16 code units, breaking between lines where
+// possible. A line longer than limit is cut between characters, never inside a tag or an entity, and the
+// tags open at the cut are closed in one part and reopened in the next. Markup counts towards the length,
+// so every part also fits once Telegram strips it.
+func Split(text string, limit int) []string {
+	if length(text) <= limit {
+		return []string{text}
+	}
+
+	var parts []string
+	var current strings.Builder
+	currentLength := 0
+	flush := func() {
+		if current.Len() > 0 {
+			parts = append(parts, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+			currentLength = 0
+		}
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineLength := length(line)
+		if currentLength+lineLength > limit {
+			flush()
+		}
+		if lineLength > limit {
+			pieces := cutLine(line, limit)
+			for _, piece := range pieces[:len(pieces)-1] {
+				parts = append(parts, piece)
+			}
+			line = pieces[len(pieces)-1]
+			lineLength = length(line)
+		}
+		current.WriteString(line)
+		currentLength += lineLength
+	}
+	flush()
+	return parts
+}
+
+// cutLine cuts a line into pieces of at most limit UTF-16 code units that each have balanced tags.
+func cutLine(line string, limit int) []string {
+	var pieces []string
+	var open []string // names of the tags open at the current position, innermost last
+	var piece strings.Builder
+	pieceLength := 0
+
+	// reopen starts a piece with the tags that are open, closing ends a piece by closing them.
+	reopen := func() string {
+		var sb strings.Builder
+		for _, name := range open {
+			sb.WriteString("<" + name + ">")
+		}
+		return sb.String()
+	}
+	closing := func() string {
+		var sb strings.Builder
+		for i := len(open) - 1; i >= 0; i-- {
+			sb.WriteString("</" + open[i] + ">")
+		}
+		return sb.String()
+	}
+
+	for rest := line; rest != ""; {
+		token, name, closes := nextToken(rest)
+		rest = rest[len(token):]
+
+		tokenLength := length(token)
+		closeLength := length(closing())
+		if name != "" && !closes {
+			closeLength += len("</" + name + ">")
+		}
+		// A closing tag always fits: its length is reserved while the tag is open.
+		if !closes && pieceLength > 0 && pieceLength+tokenLength+closeLength > limit {
+			piece.WriteString(closing())
+			pieces = append(pieces, piece.String())
+			piece.Reset()
+			piece.WriteString(reopen())
+			pieceLength = length(piece.String())
+		}
+
+		piece.WriteString(token)
+		pieceLength += tokenLength
+		switch {
+		case name != "" && closes && len(open) > 0:
+			open = open[:len(open)-1]
+		case name != "" && !closes:
+			open = append(open, name)
+		}
+	}
+	return append(pieces, piece.String())
+}
+
+// nextToken returns the tag, the entity or the single character at the start of s. For a tag it also
+// returns the tag name and whether it is a closing tag.
+func nextToken(s string) (token, name string, closes bool) {
+	switch s[0] {
+	case '<':
+		if end := strings.IndexByte(s, '>'); end > 0 {
+			token = s[:end+1]
+			name = strings.TrimPrefix(token[1:end], "/")
+			if i := strings.IndexAny(name, " \t"); i >= 0 {
+				name = name[:i]
+			}
+			return token, name, strings.HasPrefix(token, "</")
+		}
+	case '&':
+		if end := strings.IndexByte(s, ';'); end > 0 {
+			return s[:end+1], "", false
+		}
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size], "", false
+}
+
+// length counts s in UTF-16 code units, the way Telegram measures message length.
+func length(s string) int {
+	n := 0
+	for _, r := range s {
+		// Characters outside the Basic Multilingual Plane, such as most emoji, take a surrogate pair.
+		if r > 0xFFFF {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
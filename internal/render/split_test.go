@@ -0,0 +1,109 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// formatParts renders split parts for a golden file, each headed by its number and length.
+func formatParts(parts []string) string {
+	var sb strings.Builder
+	for i, part := range parts {
+		fmt.Fprintf(&sb, "=== part %d, %d units ===\n%s\n", i+1, length(part), part)
+	}
+	return sb.String()
+}
+
+// checkParts fails unless every part fits into limit and has balanced tags.
+func checkParts(t *testing.T, parts []string, limit int) {
+	t.Helper()
+	for i, part := range parts {
+		if n := length(part); n > limit {
+			t.Errorf("part %d is %d units long, limit %d", i+1, n, limit)
+		}
+		var open []string
+		for rest := part; rest != ""; {
+			token, name, closes := nextToken(rest)
+			rest = rest[len(token):]
+			switch {
+			case name == "":
+			case !closes:
+				open = append(open, name)
+			case len(open) == 0 || open[len(open)-1] != name:
+				t.Errorf("part %d closes <%s> that is not open: %q", i+1, name, part)
+			default:
+				open = open[:len(open)-1]
+			}
+		}
+		if len(open) > 0 {
+			t.Errorf("part %d leaves %v open: %q", i+1, open, part)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"Еда", 3},
+		{"€", 1},
+		{"😀", 2},
+		{"🍔 Еда", 6},
+		{"🇷🇺", 4},
+		{"👨‍👩‍👧", 8}, // three surrogate pairs joined by two zero-width joiners
+		{"<b>x</b>", 8},
+	}
+	for _, tt := range tests {
+		if got := length(tt.in); got != tt.want {
+			t.Errorf("length(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitShort(t *testing.T) {
+	text := "<b>Еда</b>: 400.0\nТакси: 200.0"
+	if parts := Split(text, len(text)); len(parts) != 1 || parts[0] != text {
+		t.Errorf("Split() = %q, want the text as is", parts)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	var lines, stats, longLine Message
+	for i := 1; i <= 12; i++ {
+		lines.Textf("%02d.06 ", i).Bold("Кафе & бары").Textf(": %d.0", i*100).Line()
+	}
+	for i := 0; i < 300; i++ {
+		stats.Text("  - 🍔 ").Bold("Еда").Text(": 400.0 (40%) ▓▓▓▓░░░░░░").Line()
+	}
+	longLine.Text("Описание: ").Bold(strings.Repeat("Такси ", 800)).Text("конец")
+
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+	}{
+		// whole lines go into a part while they fit
+		{"split_lines", lines.String(), 100},
+		{"split_max_length", stats.String(), MaxLength},
+		// a single line longer than MaxLength is cut inside the bold text, which is closed and reopened
+		{"split_long_line", longLine.String(), MaxLength},
+		{"split_reopen", "Итого: <b>очень длинная жирная строка</b> и <code>код</code> в конце", 24},
+		{"split_nested", "<b>жирный <i>курсив и ещё курсив</i> снова жирный</b>", 20},
+		// entities are never cut
+		{"split_entities", "&lt;&amp;&gt;&lt;&amp;&gt;&lt;&amp;&gt;", 10},
+		// a surrogate pair counts as two units and is never cut
+		{"split_surrogates", strings.Repeat("😀", 12) + "\n" + strings.Repeat("ё😀", 5), 9},
+		{"split_surrogates_bold", "<b>" + strings.Repeat("🍔", 10) + "</b>", 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := Split(tt.text, tt.limit)
+			checkParts(t, parts, tt.limit)
+			golden(t, tt.name, formatParts(parts))
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+var ErrAccountExists = errors.New("account already exists")
+
+func (s *Storage) AddAccount(account model.Account) error {
+	defer observe("AddAccount", time.Now())
+	query := `INSERT INTO accounts (chat_id, name, opening_balance) VALUES ($1, $2, $3)`
+	_, err := s.pool.Exec(context.Background(), query, account.ChatID, account.Name, account.OpeningBalance)
+	if isUniqueViolation(err) {
+		return ErrAccountExists
+	}
+	return err
+}
+
+func (s *Storage) GetAccountsByChatID(chatID int64) ([]model.Account, error) {
+	defer observe("GetAccountsByChatID", time.Now())
+	query := `SELECT id, chat_id, name, opening_balance, created_at FROM accounts WHERE chat_id = $1 ORDER BY id`
+	rows, err := s.pool.Query(context.Background(), query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []model.Account
+	for rows.Next() {
+		var a model.Account
+		if err := rows.Scan(&a.ID, &a.ChatID, &a.Name, &a.OpeningBalance, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+
+	return accounts, rows.Err()
+}
+
+// GetAccountBalances returns the current balance of every account: the opening balance plus income,
+// minus expenses, minus outgoing and plus incoming transfers.
+func (s *Storage) GetAccountBalances(chatID int64) ([]model.AccountBalance, error) {
+	defer observe("GetAccountBalances", time.Now())
+	query := `SELECT a.id, a.chat_id, a.name, a.opening_balance, a.created_at,
+                     a.opening_balance + COALESCE(SUM(
+                         CASE
+                             WHEN t.transaction_type = 1 THEN t.amount
+                             WHEN t.transaction_type = 2 THEN -t.amount
+                             WHEN t.transaction_type = 3 AND t.account_id = a.id THEN -t.amount
+                             WHEN t.transaction_type = 3 THEN t.amount
+                         END), 0)
+              FROM accounts a
+              LEFT JOIN transactions t
+                        ON t.chat_id = a.chat_id AND (t.account_id = a.id OR t.transfer_account_id = a.id)
+              WHERE a.chat_id = $1
+              GROUP BY a.id
+              ORDER BY a.id`
+	rows, err := s.pool.Query(context.Background(), query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []model.AccountBalance
+	for rows.Next() {
+		var b model.AccountBalance
+		a := &b.Account
+		if err := rows.Scan(&a.ID, &a.ChatID, &a.Name, &a.OpeningBalance, &a.CreatedAt, &b.Balance); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+
+	return balances, rows.Err()
+}
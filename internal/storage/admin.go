@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+// GetAdminStats counts the users and the transactions of each of the last days, today included.
+func (s *Storage) GetAdminStats(days int) (model.AdminStats, error) {
+	defer observe("GetAdminStats", time.Now())
+	ctx := context.Background()
+	stats := model.AdminStats{}
+
+	query := `SELECT (SELECT count(*) FROM users), (SELECT count(*) FROM blocked_users)`
+	if err := s.pool.QueryRow(ctx, query).Scan(&stats.Users, &stats.BlockedUsers); err != nil {
+		return model.AdminStats{}, err
+	}
+
+	query = `SELECT d::date, count(t.id)
+             FROM generate_series(current_date - ($1::integer - 1), current_date, interval '1 day') d
+                      LEFT JOIN transactions t ON t.created_at >= d AND t.created_at < d + interval '1 day'
+             GROUP BY d
+             ORDER BY d`
+	rows, err := s.pool.Query(ctx, query, days)
+	if err != nil {
+		return model.AdminStats{}, err
+	}
+	stats.TransactionsPerDay, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.DailyCount, error) {
+		c := model.DailyCount{}
+		err := row.Scan(&c.Day, &c.Count)
+		return c, err
+	})
+	return stats, err
+}
+
+// GetUserChatIDs returns the chats of all users that are not blocked.
+func (s *Storage) GetUserChatIDs() ([]int64, error) {
+	defer observe("GetUserChatIDs", time.Now())
+	query := `SELECT chat_id FROM users
+              WHERE chat_id NOT IN (SELECT chat_id FROM blocked_users)
+              ORDER BY chat_id`
+	rows, err := s.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+func (s *Storage) GetBlockedUsers() ([]int64, error) {
+	defer observe("GetBlockedUsers", time.Now())
+	rows, err := s.pool.Query(context.Background(), `SELECT chat_id FROM blocked_users`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+// BlockUser blocks the chat and reports whether it was not blocked before.
+func (s *Storage) BlockUser(chatID, adminID int64) (bool, error) {
+	defer observe("BlockUser", time.Now())
+	query := `INSERT INTO blocked_users (chat_id, blocked_by) VALUES ($1, $2) ON CONFLICT (chat_id) DO NOTHING`
+	tag, err := s.pool.Exec(context.Background(), query, chatID, adminID)
+	return tag.RowsAffected() > 0, err
+}
+
+// UnblockUser unblocks the chat and reports whether it was blocked.
+func (s *Storage) UnblockUser(chatID int64) (bool, error) {
+	defer observe("UnblockUser", time.Now())
+	tag, err := s.pool.Exec(context.Background(), `DELETE FROM blocked_users WHERE chat_id = $1`, chatID)
+	return tag.RowsAffected() > 0, err
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+// SetAPIToken stores the hash of a new API token of the chat, replacing the previous token.
+func (s *Storage) SetAPIToken(chatID int64, tokenHash []byte) error {
+	defer observe("SetAPIToken", time.Now())
+	query := `INSERT INTO api_tokens (chat_id, token_hash) VALUES ($1, $2)
+              ON CONFLICT (chat_id) DO UPDATE SET token_hash = $2, created_at = now(), last_used_at = NULL`
+	_, err := s.pool.Exec(context.Background(), query, chatID, tokenHash)
+	return err
+}
+
+// DeleteAPIToken revokes the API token of the chat. It reports whether there was one.
+func (s *Storage) DeleteAPIToken(chatID int64) (bool, error) {
+	defer observe("DeleteAPIToken", time.Now())
+	tag, err := s.pool.Exec(context.Background(), `DELETE FROM api_tokens WHERE chat_id = $1`, chatID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetChatIDByAPIToken returns the chat owning the token with the given hash and records its use, or
+// pgx.ErrNoRows for an unknown token.
+func (s *Storage) GetChatIDByAPIToken(tokenHash []byte) (int64, error) {
+	defer observe("GetChatIDByAPIToken", time.Now())
+	query := `UPDATE api_tokens SET last_used_at = now() WHERE token_hash = $1 RETURNING chat_id`
+	var chatID int64
+	err := s.pool.QueryRow(context.Background(), query, tokenHash).Scan(&chatID)
+	return chatID, err
+}
+
+// GetAPIToken returns when the token of the chat was created and last used, or pgx.ErrNoRows.
+func (s *Storage) GetAPIToken(chatID int64) (model.APIToken, error) {
+	defer observe("GetAPIToken", time.Now())
+	query := `SELECT chat_id, created_at, last_used_at FROM api_tokens WHERE chat_id = $1`
+	var (
+		t        model.APIToken
+		lastUsed *time.Time
+	)
+	err := s.pool.QueryRow(context.Background(), query, chatID).Scan(&t.ChatID, &t.CreatedAt, &lastUsed)
+	if lastUsed != nil {
+		t.LastUsedAt = *lastUsed
+	}
+	return t, err
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+// WithActor returns a storage whose changes the audit log attributes to the user and the source, one of the
+// model.AuditSource constants. Without an actor the audit_row trigger records the chat owning the row and
+// the bot as the source.
+func (s *Storage) WithActor(actorID int64, source string) *Storage {
+	return &Storage{pool: s.pool, actorID: actorID, source: source}
+}
+
+// begin starts a transaction for audited changes. The actor is set locally to the transaction, where
+// audit_row reads it, so that it does not leak to other users of the pooled connection.
+func (s *Storage) begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil || s.actorID == 0 {
+		return tx, err
+	}
+	query := `SELECT set_config('budget.actor_id', $1, true), set_config('budget.source', $2, true)`
+	if _, err := tx.Exec(ctx, query, strconv.FormatInt(s.actorID, 10), s.source); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return tx, nil
+}
+
+// exec runs a single audited statement in a transaction of begin.
+func (s *Storage) exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return tag, err
+	}
+	return tag, tx.Commit(ctx)
+}
+
+// GetAuditLog returns the latest changes of the chat, newest first. An empty entity returns changes of
+// every entity, otherwise only those of the entity with the ID.
+func (s *Storage) GetAuditLog(chatID int64, entity string, entityID int64, limit int) ([]model.AuditEntry, error) {
+	defer observe("GetAuditLog", time.Now())
+	query := `SELECT id, chat_id, actor_id, source, entity, entity_id, operation, before, after, created_at
+              FROM audit_log
+              WHERE chat_id = $1 AND ($2 = '' OR (entity = $2 AND entity_id = $3))
+              ORDER BY created_at DESC, id DESC
+              LIMIT $4`
+	rows, err := s.pool.Query(context.Background(), query, chatID, entity, entityID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.AuditEntry, error) {
+		e := model.AuditEntry{}
+		err := row.Scan(
+			&e.ID, &e.ChatID, &e.ActorID, &e.Source, &e.Entity, &e.EntityID, &e.Operation, &e.Before, &e.After, &e.CreatedAt,
+		)
+		return e, err
+	})
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+var ErrRepaymentTooLarge = errors.New("repayment exceeds the remaining debt")
+
+const debtColumns = `d.id, d.chat_id, d.counterparty, d.direction, d.amount,
+                     COALESCE((SELECT SUM(r.amount) FROM debt_repayments r WHERE r.debt_id = d.id), 0),
+                     d.due_date, d.created_at`
+
+func (s *Storage) AddDebt(debt model.Debt) error {
+	defer observe("AddDebt", time.Now())
+	var dueDate *time.Time
+	if !debt.DueDate.IsZero() {
+		dueDate = &debt.DueDate
+	}
+	query := `INSERT INTO debts (chat_id, counterparty, direction, amount, due_date) VALUES ($1, $2, $3, $4, $5)`
+	_, err := s.pool.Exec(context.Background(), query, debt.ChatID, debt.Counterparty, debt.Direction, debt.Amount, dueDate)
+	return err
+}
+
+// GetOpenDebts returns the debts of the chat that are not fully repaid, closest due date first.
+func (s *Storage) GetOpenDebts(chatID int64) ([]model.Debt, error) {
+	defer observe("GetOpenDebts", time.Now())
+	query := `SELECT ` + debtColumns + `
+              FROM debts d
+              WHERE d.chat_id = $1
+                AND d.amount > COALESCE((SELECT SUM(r.amount) FROM debt_repayments r WHERE r.debt_id = d.id), 0)
+              ORDER BY d.due_date NULLS LAST, d.id`
+	rows, err := s.pool.Query(context.Background(), query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return collectDebts(rows)
+}
+
+// GetDebtsByChatID returns all debts of the chat, repaid ones included.
+func (s *Storage) GetDebtsByChatID(chatID int64) ([]model.Debt, error) {
+	defer observe("GetDebtsByChatID", time.Now())
+	query := `SELECT ` + debtColumns + ` FROM debts d WHERE d.chat_id = $1 ORDER BY d.id`
+	rows, err := s.pool.Query(context.Background(), query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return collectDebts(rows)
+}
+
+func (s *Storage) GetDebtByID(chatID, debtID int64) (model.Debt, error) {
+	defer observe("GetDebtByID", time.Now())
+	query := `SELECT ` + debtColumns + ` FROM debts d WHERE d.chat_id = $1 AND d.id = $2`
+	rows, err := s.pool.Query(context.Background(), query, chatID, debtID)
+	if err != nil {
+		return model.Debt{}, err
+	}
+	debts, err := collectDebts(rows)
+	if err != nil {
+		return model.Debt{}, err
+	}
+	if len(debts) == 0 {
+		return model.Debt{}, pgx.ErrNoRows
+	}
+	return debts[0], nil
+}
+
+// AddDebtRepayment records a partial or full repayment. It fails with ErrRepaymentTooLarge when the
+// amount exceeds what is left of the debt.
+func (s *Storage) AddDebtRepayment(chatID, debtID int64, amount float64) error {
+	defer observe("AddDebtRepayment", time.Now())
+	ctx := context.Background()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var remaining float64
+	query := `SELECT d.amount - COALESCE((SELECT SUM(r.amount) FROM debt_repayments r WHERE r.debt_id = d.id), 0)
+              FROM debts d
+              WHERE d.chat_id = $1 AND d.id = $2
+              FOR UPDATE`
+	if err := tx.QueryRow(ctx, query, chatID, debtID).Scan(&remaining); err != nil {
+		return err
+	}
+	if amount > remaining {
+		return ErrRepaymentTooLarge
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO debt_repayments (debt_id, amount) VALUES ($1, $2)`, debtID, amount); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetDueDebts returns open debts of all users that are due on or before the date and were not reminded about yet.
+func (s *Storage) GetDueDebts(date time.Time) ([]model.Debt, error) {
+	defer observe("GetDueDebts", time.Now())
+	query := `SELECT ` + debtColumns + `
+              FROM debts d
+              WHERE d.due_date <= $1
+                AND d.reminded_at IS NULL
+                AND d.amount > COALESCE((SELECT SUM(r.amount) FROM debt_repayments r WHERE r.debt_id = d.id), 0)
+              ORDER BY d.id`
+	rows, err := s.pool.Query(context.Background(), query, date)
+	if err != nil {
+		return nil, err
+	}
+	return collectDebts(rows)
+}
+
+func (s *Storage) MarkDebtReminded(debtID int64) error {
+	defer observe("MarkDebtReminded", time.Now())
+	query := `UPDATE debts SET reminded_at = now() WHERE id = $1`
+	_, err := s.pool.Exec(context.Background(), query, debtID)
+	return err
+}
+
+func collectDebts(rows pgx.Rows) ([]model.Debt, error) {
+	defer rows.Close()
+
+	var debts []model.Debt
+	for rows.Next() {
+		var (
+			d       model.Debt
+			dueDate *time.Time
+		)
+		if err := rows.Scan(
+			&d.ID, &d.ChatID, &d.Counterparty, &d.Direction, &d.Amount, &d.Repaid, &dueDate, &d.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if dueDate != nil {
+			d.DueDate = *dueDate
+		}
+		debts = append(debts, d)
+	}
+
+	return debts, rows.Err()
+}
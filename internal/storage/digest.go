@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+func (s *Storage) SetUserTimezone(chatID int64, timezone string) error {
+	defer observe("SetUserTimezone", time.Now())
+	query := `UPDATE users SET timezone = $1 WHERE chat_id = $2`
+	_, err := s.pool.Exec(context.Background(), query, timezone, chatID)
+	return err
+}
+
+// SetDigestSettings enables or reconfigures the digest of a chat.
+func (s *Storage) SetDigestSettings(settings model.DigestSettings) error {
+	defer observe("SetDigestSettings", time.Now())
+	query := `INSERT INTO digest_settings (chat_id, period, send_minute, last_sent_at)
+              VALUES ($1, $2, $3, $4)
+              ON CONFLICT (chat_id) DO UPDATE
+                  SET period       = excluded.period,
+                      send_minute  = excluded.send_minute,
+                      last_sent_at = excluded.last_sent_at`
+	_, err := s.pool.Exec(
+		context.Background(), query, settings.ChatID, settings.Period, settings.SendMinute, settings.LastSentAt,
+	)
+	return err
+}
+
+func (s *Storage) DeleteDigestSettings(chatID int64) error {
+	defer observe("DeleteDigestSettings", time.Now())
+	query := `DELETE FROM digest_settings WHERE chat_id = $1`
+	_, err := s.pool.Exec(context.Background(), query, chatID)
+	return err
+}
+
+// GetDigestSettings returns the digest settings of every chat that enabled digests.
+func (s *Storage) GetDigestSettings() ([]model.DigestSettings, error) {
+	defer observe("GetDigestSettings", time.Now())
+	query := `SELECT d.chat_id, d.period, d.send_minute, u.timezone, d.last_sent_at
+              FROM digest_settings d
+              JOIN users u ON u.chat_id = d.chat_id
+              ORDER BY d.chat_id`
+	rows, err := s.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings []model.DigestSettings
+	for rows.Next() {
+		var d model.DigestSettings
+		if err := rows.Scan(&d.ChatID, &d.Period, &d.SendMinute, &d.Timezone, &d.LastSentAt); err != nil {
+			return nil, err
+		}
+		settings = append(settings, d)
+	}
+
+	return settings, rows.Err()
+}
+
+// GetDigestSettingsByChatID returns the digest settings of the chat, with DigestPeriodOff when digests are disabled.
+func (s *Storage) GetDigestSettingsByChatID(chatID int64) (model.DigestSettings, error) {
+	defer observe("GetDigestSettingsByChatID", time.Now())
+	query := `SELECT u.chat_id, COALESCE(d.period, 0), COALESCE(d.send_minute, 0), u.timezone,
+                     COALESCE(d.last_sent_at, 'epoch'::timestamptz)
+              FROM users u
+              LEFT JOIN digest_settings d ON d.chat_id = u.chat_id
+              WHERE u.chat_id = $1`
+	var d model.DigestSettings
+	err := s.pool.QueryRow(context.Background(), query, chatID).Scan(
+		&d.ChatID, &d.Period, &d.SendMinute, &d.Timezone, &d.LastSentAt,
+	)
+	return d, err
+}
+
+// ClaimDigest moves the last-sent marker of a chat from prev to due. It reports false when the marker is
+// no longer prev, e.g. because the settings changed or another run already claimed the digest.
+func (s *Storage) ClaimDigest(chatID int64, prev, due time.Time) (bool, error) {
+	defer observe("ClaimDigest", time.Now())
+	query := `UPDATE digest_settings SET last_sent_at = $1 WHERE chat_id = $2 AND last_sent_at = $3`
+	tag, err := s.pool.Exec(context.Background(), query, due, chatID, prev)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
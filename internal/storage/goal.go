@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+var ErrAlreadyContributed = errors.New("transaction already contributes to a goal")
+
+func (s *Storage) AddGoal(goal model.Goal) error {
+	defer observe("AddGoal", time.Now())
+	query := `INSERT INTO goals (chat_id, name, target_amount, deadline) VALUES ($1, $2, $3, $4)`
+	_, err := s.pool.Exec(context.Background(), query, goal.ChatID, goal.Name, goal.TargetAmount, goal.Deadline)
+	return err
+}
+
+// GetGoalsByChatID returns the goals with the sum of their contributions, closest deadline first.
+func (s *Storage) GetGoalsByChatID(chatID int64) ([]model.Goal, error) {
+	defer observe("GetGoalsByChatID", time.Now())
+	query := `SELECT g.id, g.chat_id, g.name, g.target_amount, g.deadline, g.created_at, COALESCE(SUM(gc.amount), 0)
+              FROM goals g
+              LEFT JOIN goal_contributions gc ON gc.goal_id = g.id
+              WHERE g.chat_id = $1
+              GROUP BY g.id
+              ORDER BY g.deadline, g.id`
+	rows, err := s.pool.Query(context.Background(), query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var goals []model.Goal
+	for rows.Next() {
+		var g model.Goal
+		if err := rows.Scan(&g.ID, &g.ChatID, &g.Name, &g.TargetAmount, &g.Deadline, &g.CreatedAt, &g.Saved); err != nil {
+			return nil, err
+		}
+		goals = append(goals, g)
+	}
+
+	return goals, rows.Err()
+}
+
+// GetGoalsToNudge returns the goals whose deadline is after now, created and last nudged no later than since,
+// with the sum of their contributions. Whether a goal is behind pace is left to the caller.
+func (s *Storage) GetGoalsToNudge(now, since time.Time) ([]model.Goal, error) {
+	defer observe("GetGoalsToNudge", time.Now())
+	query := `SELECT g.id, g.chat_id, g.name, g.target_amount, g.deadline, g.created_at, COALESCE(SUM(gc.amount), 0)
+              FROM goals g
+              LEFT JOIN goal_contributions gc ON gc.goal_id = g.id
+              WHERE g.deadline > $1
+                AND g.created_at <= $2
+                AND (g.nudged_at IS NULL OR g.nudged_at <= $2)
+              GROUP BY g.id
+              ORDER BY g.id`
+	rows, err := s.pool.Query(context.Background(), query, now, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var goals []model.Goal
+	for rows.Next() {
+		var g model.Goal
+		if err := rows.Scan(&g.ID, &g.ChatID, &g.Name, &g.TargetAmount, &g.Deadline, &g.CreatedAt, &g.Saved); err != nil {
+			return nil, err
+		}
+		goals = append(goals, g)
+	}
+
+	return goals, rows.Err()
+}
+
+func (s *Storage) MarkGoalNudged(goalID int64) error {
+	defer observe("MarkGoalNudged", time.Now())
+	_, err := s.pool.Exec(context.Background(), `UPDATE goals SET nudged_at = now() WHERE id = $1`, goalID)
+	return err
+}
+
+// AddGoalContribution attributes the full amount of a transaction or transfer to a goal of the same chat.
+// A transaction can contribute to one goal only.
+func (s *Storage) AddGoalContribution(chatID, goalID, transactionID int64) error {
+	defer observe("AddGoalContribution", time.Now())
+	query := `INSERT INTO goal_contributions (goal_id, transaction_id, amount)
+              SELECT g.id, t.id, t.amount
+              FROM goals g
+              JOIN transactions t ON t.chat_id = g.chat_id
+              WHERE g.chat_id = $1
+                AND g.id = $2
+                AND t.id = $3`
+	tag, err := s.pool.Exec(context.Background(), query, chatID, goalID, transactionID)
+	if isUniqueViolation(err) {
+		return ErrAlreadyContributed
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("goal or transaction not found")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+// SQL literals of the action kinds for the statements that journal actions.
+var (
+	actionAddTransaction = strconv.Itoa(model.ActionAddTransaction)
+	actionRenameCategory = strconv.Itoa(model.ActionRenameCategory)
+)
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// UndoLastAction reverts the most recent action of the chat that is not undone and not older than window.
+// A non-zero kind and target restrict the undo to that action: it fails with ErrNothingToUndo when a later
+// action was made since. The returned action has Amount filled for an undone transaction.
+func (s *Storage) UndoLastAction(chatID int64, window time.Duration, kind uint8, targetID int64) (model.Action, error) {
+	defer observe("UndoLastAction", time.Now())
+	ctx := context.Background()
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return model.Action{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT id, chat_id, kind, target_id, old_value, created_at
+              FROM action_journal
+              WHERE chat_id = $1 AND undone_at IS NULL AND created_at > $2
+              ORDER BY created_at DESC, id DESC
+              LIMIT 1
+              FOR UPDATE`
+	a := model.Action{}
+	err = tx.QueryRow(ctx, query, chatID, time.Now().Add(-window)).Scan(
+		&a.ID, &a.ChatID, &a.Kind, &a.TargetID, &a.OldValue, &a.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Action{}, ErrNothingToUndo
+	}
+	if err != nil {
+		return model.Action{}, err
+	}
+	if kind != 0 && (a.Kind != kind || a.TargetID != targetID) {
+		return model.Action{}, ErrNothingToUndo
+	}
+
+	switch a.Kind {
+	case model.ActionAddTransaction:
+		query = `DELETE FROM goal_contributions WHERE transaction_id = $1`
+		if _, err := tx.Exec(ctx, query, a.TargetID); err != nil {
+			return model.Action{}, err
+		}
+		query = `DELETE FROM transactions WHERE chat_id = $1 AND id = $2 RETURNING amount`
+		if err := tx.QueryRow(ctx, query, chatID, a.TargetID).Scan(&a.Amount); err != nil {
+			return model.Action{}, err
+		}
+	case model.ActionRenameCategory:
+		query = `UPDATE categories SET name = $1 WHERE chat_id = $2 AND id = $3`
+		if _, err := tx.Exec(ctx, query, a.OldValue, chatID, a.TargetID); err != nil {
+			return model.Action{}, mapCategoryError(err)
+		}
+	}
+
+	query = `UPDATE action_journal SET undone_at = now() WHERE id = $1`
+	if _, err := tx.Exec(ctx, query, a.ID); err != nil {
+		return model.Action{}, err
+	}
+	return a, tx.Commit(ctx)
+}
+
+// DeleteJournalBefore forgets the actions that can no longer be undone.
+func (s *Storage) DeleteJournalBefore(before time.Time) error {
+	defer observe("DeleteJournalBefore", time.Now())
+	_, err := s.pool.Exec(context.Background(), `DELETE FROM action_journal WHERE created_at < $1`, before)
+	return err
+}
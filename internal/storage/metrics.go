@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cupitman9/budget-bot/internal/metrics"
+)
+
+// observe records the latency of a Storage method: defer observe("Method", time.Now()).
+func observe(method string, start time.Time) {
+	metrics.StorageQueryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// Stat returns the connection pool statistics.
+func (s *Storage) Stat() *pgxpool.Stat {
+	return s.pool.Stat()
+}
+
+// Ping checks that the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
@@ -0,0 +1,19 @@
+UPDATE categories
+SET name = left(regexp_replace(btrim(name), '\s+', ' ', 'g'), 50);
+
+UPDATE categories
+SET name = 'Без названия'
+WHERE name = '';
+
+UPDATE categories c
+SET name = left(c.name, 40) || ' ' || c.id
+WHERE EXISTS (SELECT 1
+              FROM categories d
+              WHERE d.chat_id = c.chat_id
+                AND lower(d.name) = lower(c.name)
+                AND d.id < c.id);
+
+ALTER TABLE categories
+    ADD CONSTRAINT categories_name_length_check CHECK (char_length(name) BETWEEN 1 AND 50 AND name = btrim(name));
+
+CREATE UNIQUE INDEX categories_chat_id_lower_name_key ON categories (chat_id, lower(name));
@@ -0,0 +1,8 @@
+ALTER TABLE categories
+    ADD COLUMN parent_id bigint REFERENCES categories (id);
+
+DROP INDEX categories_chat_id_lower_name_key;
+
+CREATE UNIQUE INDEX categories_chat_id_parent_id_lower_name_key ON categories (chat_id, COALESCE(parent_id, 0), lower(name));
+
+CREATE INDEX categories_parent_id_idx ON categories (parent_id);
@@ -0,0 +1,6 @@
+ALTER TABLE categories
+    ADD COLUMN kind       smallint    NOT NULL DEFAULT 0 CHECK (kind IN (0, 1, 2)), -- 0 = both 1 = income 2 = expense
+    ADD COLUMN icon       varchar(16) NOT NULL DEFAULT '',
+    ADD COLUMN sort_order integer     NOT NULL DEFAULT 0;
+
+CREATE INDEX transactions_chat_id_category_id_idx ON transactions (chat_id, category_id);
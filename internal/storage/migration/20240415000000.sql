@@ -0,0 +1,37 @@
+CREATE TABLE accounts
+(
+    id              bigserial PRIMARY KEY,
+    chat_id         bigint         NOT NULL REFERENCES users (chat_id),
+    name            varchar(50)    NOT NULL,
+    opening_balance numeric(12, 2) NOT NULL DEFAULT 0,
+    created_at      timestamp      NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX accounts_chat_id_lower_name_key ON accounts (chat_id, lower(name));
+
+INSERT INTO accounts (chat_id, name)
+SELECT chat_id, 'Основной'
+FROM users;
+
+ALTER TABLE transactions
+    DROP CONSTRAINT transactions_pkey,
+    ADD COLUMN id                  bigserial PRIMARY KEY,
+    ALTER COLUMN category_id DROP NOT NULL,
+    ALTER COLUMN category_id DROP DEFAULT,
+    ADD COLUMN account_id          bigint REFERENCES accounts (id),
+    ADD COLUMN transfer_account_id bigint REFERENCES accounts (id); -- destination of a transfer
+
+UPDATE transactions t
+SET account_id = a.id
+FROM accounts a
+WHERE a.chat_id = t.chat_id;
+
+ALTER TABLE transactions
+    ALTER COLUMN account_id SET NOT NULL,
+    ADD CONSTRAINT transactions_type_check CHECK (
+        (transaction_type IN (1, 2) AND category_id IS NOT NULL AND transfer_account_id IS NULL) OR
+        (transaction_type = 3 AND category_id IS NULL AND transfer_account_id IS NOT NULL AND
+         transfer_account_id <> account_id)
+        ); -- 3 = transfer
+
+CREATE INDEX transactions_chat_id_created_at_idx ON transactions (chat_id, created_at);
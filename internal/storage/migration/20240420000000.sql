@@ -0,0 +1,22 @@
+CREATE TABLE goals
+(
+    id            bigserial PRIMARY KEY,
+    chat_id       bigint         NOT NULL REFERENCES users (chat_id),
+    name          varchar(50)    NOT NULL,
+    target_amount numeric(12, 2) NOT NULL CHECK (target_amount > 0),
+    deadline      date           NOT NULL,
+    created_at    timestamp      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX goals_chat_id_idx ON goals (chat_id);
+
+CREATE TABLE goal_contributions
+(
+    id             bigserial PRIMARY KEY,
+    goal_id        bigint         NOT NULL REFERENCES goals (id),
+    transaction_id bigint         NOT NULL UNIQUE REFERENCES transactions (id),
+    amount         numeric(12, 2) NOT NULL,
+    created_at     timestamp      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX goal_contributions_goal_id_idx ON goal_contributions (goal_id);
@@ -0,0 +1,24 @@
+CREATE TABLE debts
+(
+    id           bigserial PRIMARY KEY,
+    chat_id      bigint         NOT NULL REFERENCES users (chat_id),
+    counterparty varchar(50)    NOT NULL,
+    direction    smallint       NOT NULL CHECK (direction IN (1, 2)), -- 1 = lent 2 = borrowed
+    amount       numeric(12, 2) NOT NULL CHECK (amount > 0),
+    due_date     date,
+    reminded_at  timestamp,
+    created_at   timestamp      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX debts_chat_id_idx ON debts (chat_id);
+CREATE INDEX debts_due_date_idx ON debts (due_date) WHERE reminded_at IS NULL;
+
+CREATE TABLE debt_repayments
+(
+    id         bigserial PRIMARY KEY,
+    debt_id    bigint         NOT NULL REFERENCES debts (id),
+    amount     numeric(12, 2) NOT NULL CHECK (amount > 0),
+    created_at timestamp      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX debt_repayments_debt_id_idx ON debt_repayments (debt_id);
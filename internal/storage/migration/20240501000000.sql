@@ -0,0 +1,11 @@
+ALTER TABLE users
+    ADD COLUMN timezone varchar(64) NOT NULL DEFAULT 'Europe/Moscow';
+
+CREATE TABLE digest_settings
+(
+    chat_id      bigint    NOT NULL PRIMARY KEY REFERENCES users (chat_id),
+    period       smallint  NOT NULL CHECK (period IN (1, 2, 3)), -- 1 = daily 2 = weekly 3 = monthly
+    send_minute  integer   NOT NULL CHECK (send_minute BETWEEN 0 AND 1439), -- local time, minutes after midnight
+    last_sent_at timestamptz NOT NULL,                          -- scheduled moment of the last digest sent
+    created_at   timestamp NOT NULL DEFAULT now()
+);
@@ -0,0 +1,8 @@
+CREATE TABLE reminder_settings
+(
+    chat_id       bigint    NOT NULL PRIMARY KEY REFERENCES users (chat_id),
+    send_minute   integer   NOT NULL CHECK (send_minute BETWEEN 0 AND 1439), -- local time, minutes after midnight
+    snoozed_until timestamptz,
+    last_sent_on  date,                                                       -- local date of the last reminder
+    created_at    timestamp NOT NULL DEFAULT now()
+);
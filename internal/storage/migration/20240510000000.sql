@@ -0,0 +1,7 @@
+-- Users blocked by an admin. There is no reference to users: strangers who never ran /start can be blocked too.
+CREATE TABLE blocked_users
+(
+    chat_id    bigint    NOT NULL PRIMARY KEY,
+    blocked_by bigint    NOT NULL,
+    created_at timestamp NOT NULL DEFAULT now()
+);
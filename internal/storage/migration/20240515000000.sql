@@ -0,0 +1,13 @@
+CREATE TABLE templates
+(
+    id               bigserial PRIMARY KEY,
+    chat_id          bigint         NOT NULL REFERENCES users (chat_id),
+    name             varchar(50)    NOT NULL,
+    category_id      bigint         NOT NULL REFERENCES categories (id),
+    account_id       bigint         NOT NULL REFERENCES accounts (id),
+    amount           numeric(12, 2) NOT NULL CHECK (amount > 0),
+    transaction_type smallint       NOT NULL CHECK (transaction_type IN (1, 2)), -- 1 = income 2 = expense
+    created_at       timestamp      NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX templates_chat_id_lower_name_key ON templates (chat_id, lower(name));
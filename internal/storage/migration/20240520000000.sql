@@ -0,0 +1,14 @@
+-- Journal of user actions that /undo can revert. Entries are written by the storage methods performing
+-- the action, in the same statement.
+CREATE TABLE action_journal
+(
+    id         bigserial PRIMARY KEY,
+    chat_id    bigint      NOT NULL REFERENCES users (chat_id),
+    kind       smallint    NOT NULL CHECK (kind IN (1, 2)), -- 1 = add transaction 2 = rename category
+    target_id  bigint      NOT NULL,                        -- transaction or category
+    old_value  text        NOT NULL DEFAULT '',             -- previous category name
+    created_at timestamptz NOT NULL DEFAULT now(),
+    undone_at  timestamptz
+);
+
+CREATE INDEX action_journal_chat_id_created_at_idx ON action_journal (chat_id, created_at);
@@ -0,0 +1,62 @@
+-- Append-only history of changes to categories and transactions, written by triggers in the same transaction
+-- as the change. Ledgers are private, so the actor is the chat owning the row.
+CREATE TABLE audit_log
+(
+    id         bigserial PRIMARY KEY,
+    chat_id    bigint      NOT NULL,
+    actor_id   bigint      NOT NULL,
+    entity     varchar(16) NOT NULL CHECK (entity IN ('category', 'transaction')),
+    entity_id  bigint      NOT NULL,
+    operation  varchar(8)  NOT NULL CHECK (operation IN ('insert', 'update', 'delete')),
+    before     jsonb,
+    after      jsonb,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_log_chat_id_entity_idx ON audit_log (chat_id, entity, entity_id, created_at);
+
+CREATE FUNCTION audit_log_immutable() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+-- Rows may still be deleted, which /delete_me needs.
+CREATE TRIGGER audit_log_immutable
+    BEFORE UPDATE
+    ON audit_log
+    FOR EACH ROW
+EXECUTE FUNCTION audit_log_immutable();
+
+-- audit_row records a change of the row, the first trigger argument being the entity name. Updates of the
+-- sort order alone are renumbering and are not recorded.
+CREATE FUNCTION audit_row() RETURNS trigger AS
+$$
+DECLARE
+    old_row jsonb := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+    new_row jsonb := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+    row_data jsonb := COALESCE(new_row, old_row);
+BEGIN
+    IF TG_OP = 'UPDATE' AND old_row - 'sort_order' = new_row - 'sort_order' THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO audit_log (chat_id, actor_id, entity, entity_id, operation, before, after)
+    VALUES ((row_data ->> 'chat_id')::bigint, (row_data ->> 'chat_id')::bigint, TG_ARGV[0],
+            (row_data ->> 'id')::bigint, lower(TG_OP), old_row, new_row);
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER categories_audit
+    AFTER INSERT OR UPDATE OR DELETE
+    ON categories
+    FOR EACH ROW
+EXECUTE FUNCTION audit_row('category');
+
+CREATE TRIGGER transactions_audit
+    AFTER INSERT OR UPDATE OR DELETE
+    ON transactions
+    FOR EACH ROW
+EXECUTE FUNCTION audit_row('transaction');
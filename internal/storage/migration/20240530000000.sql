@@ -0,0 +1,9 @@
+-- Personal access tokens of the REST API, one per user. Only the SHA-256 hash of a token is stored, the token
+-- itself is shown to the user once.
+CREATE TABLE api_tokens
+(
+    chat_id      bigint    NOT NULL PRIMARY KEY REFERENCES users (chat_id),
+    token_hash   bytea     NOT NULL UNIQUE,
+    created_at   timestamp NOT NULL DEFAULT now(),
+    last_used_at timestamp
+);
@@ -0,0 +1,3 @@
+-- When the owner was last told that the goal is behind pace, so that the nudge is not repeated every day.
+ALTER TABLE goals
+    ADD COLUMN nudged_at timestamp;
@@ -0,0 +1,26 @@
+-- Records who made a change and where. Storage writes set budget.actor_id and budget.source locally to their
+-- transaction, see Storage.WithActor. Changes made without them are the chat owner's own, made in the bot.
+ALTER TABLE audit_log
+    ADD COLUMN source varchar(16) NOT NULL DEFAULT 'bot' CHECK (source IN ('bot', 'webapp', 'api'));
+
+CREATE OR REPLACE FUNCTION audit_row() RETURNS trigger AS
+$$
+DECLARE
+    old_row jsonb := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+    new_row jsonb := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+    row_data jsonb := COALESCE(new_row, old_row);
+    -- A setting that was set in an earlier transaction of the connection reads as an empty string.
+    change_actor bigint := COALESCE(NULLIF(current_setting('budget.actor_id', true), '')::bigint,
+                                    (row_data ->> 'chat_id')::bigint);
+    change_source varchar := COALESCE(NULLIF(current_setting('budget.source', true), ''), 'bot');
+BEGIN
+    IF TG_OP = 'UPDATE' AND old_row - 'sort_order' = new_row - 'sort_order' THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO audit_log (chat_id, actor_id, source, entity, entity_id, operation, before, after)
+    VALUES ((row_data ->> 'chat_id')::bigint, change_actor, change_source, TG_ARGV[0],
+            (row_data ->> 'id')::bigint, lower(TG_OP), old_row, new_row);
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
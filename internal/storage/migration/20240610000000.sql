@@ -0,0 +1,13 @@
+-- Category names are unique per ledger again, not only among siblings: stats, the quick keyboard and inline
+-- results show names without their parents. Names that clash are renamed the same way as in 20240401000000.
+UPDATE categories c
+SET name = left(c.name, 40) || ' ' || c.id
+WHERE EXISTS (SELECT 1
+              FROM categories d
+              WHERE d.chat_id = c.chat_id
+                AND lower(d.name) = lower(c.name)
+                AND d.id < c.id);
+
+DROP INDEX categories_chat_id_parent_id_lower_name_key;
+
+CREATE UNIQUE INDEX categories_chat_id_lower_name_key ON categories (chat_id, lower(name));
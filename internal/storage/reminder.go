@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+// SetReminder enables the daily reminder of a chat at the local time, clearing any snooze.
+func (s *Storage) SetReminder(chatID int64, sendMinute int) error {
+	defer observe("SetReminder", time.Now())
+	query := `INSERT INTO reminder_settings (chat_id, send_minute)
+              VALUES ($1, $2)
+              ON CONFLICT (chat_id) DO UPDATE
+                  SET send_minute   = excluded.send_minute,
+                      snoozed_until = NULL`
+	_, err := s.pool.Exec(context.Background(), query, chatID, sendMinute)
+	return err
+}
+
+func (s *Storage) DeleteReminder(chatID int64) error {
+	defer observe("DeleteReminder", time.Now())
+	query := `DELETE FROM reminder_settings WHERE chat_id = $1`
+	_, err := s.pool.Exec(context.Background(), query, chatID)
+	return err
+}
+
+const reminderSettingsQuery = `SELECT r.chat_id, r.send_minute, u.timezone, r.snoozed_until, r.last_sent_on
+                               FROM reminder_settings r
+                               JOIN users u ON u.chat_id = r.chat_id`
+
+// GetReminderSettings returns the reminder settings of every chat that enabled reminders.
+func (s *Storage) GetReminderSettings() ([]model.ReminderSettings, error) {
+	defer observe("GetReminderSettings", time.Now())
+	rows, err := s.pool.Query(context.Background(), reminderSettingsQuery+` ORDER BY r.chat_id`)
+	if err != nil {
+		return nil, err
+	}
+	return collectReminderSettings(rows)
+}
+
+// GetReminderSettingsByChatID returns the reminder settings of the chat and whether reminders are enabled.
+func (s *Storage) GetReminderSettingsByChatID(chatID int64) (model.ReminderSettings, bool, error) {
+	defer observe("GetReminderSettingsByChatID", time.Now())
+	rows, err := s.pool.Query(context.Background(), reminderSettingsQuery+` WHERE r.chat_id = $1`, chatID)
+	if err != nil {
+		return model.ReminderSettings{}, false, err
+	}
+	settings, err := collectReminderSettings(rows)
+	if err != nil || len(settings) == 0 {
+		return model.ReminderSettings{}, false, err
+	}
+	return settings[0], true, nil
+}
+
+func collectReminderSettings(rows pgx.Rows) ([]model.ReminderSettings, error) {
+	defer rows.Close()
+
+	var settings []model.ReminderSettings
+	for rows.Next() {
+		var (
+			r            model.ReminderSettings
+			snoozedUntil *time.Time
+			lastSentOn   *time.Time
+		)
+		if err := rows.Scan(&r.ChatID, &r.SendMinute, &r.Timezone, &snoozedUntil, &lastSentOn); err != nil {
+			return nil, err
+		}
+		if snoozedUntil != nil {
+			r.SnoozedUntil = *snoozedUntil
+		}
+		if lastSentOn != nil {
+			r.LastSentOn = *lastSentOn
+		}
+		settings = append(settings, r)
+	}
+
+	return settings, rows.Err()
+}
+
+// MarkReminderSent remembers the local date the reminder was sent on and clears the snooze.
+func (s *Storage) MarkReminderSent(chatID int64, localDate time.Time) error {
+	defer observe("MarkReminderSent", time.Now())
+	query := `UPDATE reminder_settings SET last_sent_on = $1, snoozed_until = NULL WHERE chat_id = $2`
+	_, err := s.pool.Exec(context.Background(), query, localDate.Format(time.DateOnly), chatID)
+	return err
+}
+
+// SnoozeReminder sends the reminder again at until, unless a transaction is added before.
+func (s *Storage) SnoozeReminder(chatID int64, until time.Time) error {
+	defer observe("SnoozeReminder", time.Now())
+	query := `UPDATE reminder_settings SET snoozed_until = $1, last_sent_on = NULL WHERE chat_id = $2`
+	_, err := s.pool.Exec(context.Background(), query, until, chatID)
+	return err
+}
+
+func (s *Storage) HasTransactionsBetween(chatID int64, startDate, endDate time.Time) (bool, error) {
+	defer observe("HasTransactionsBetween", time.Now())
+	query := `SELECT EXISTS (SELECT 1 FROM transactions WHERE chat_id = $1 AND created_at >= $2 AND created_at < $3)`
+	var exists bool
+	err := s.pool.QueryRow(context.Background(), query, chatID, startDate, endDate).Scan(&exists)
+	return exists, err
+}
+
+// GetMostUsedCategories returns up to limit categories with the most transactions, most used first.
+func (s *Storage) GetMostUsedCategories(chatID int64, limit int) ([]model.Category, error) {
+	defer observe("GetMostUsedCategories", time.Now())
+	query := `SELECT ` + categoryColumns + `
+              FROM categories c
+              JOIN transactions t ON t.category_id = c.id
+              WHERE c.chat_id = $1
+              GROUP BY c.id
+              ORDER BY COUNT(*) DESC, c.id
+              LIMIT $2`
+	rows, err := s.pool.Query(context.Background(), query, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectCategories(rows)
+}
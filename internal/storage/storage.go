@@ -2,16 +2,26 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/cupitman9/budget-bot/internal/model"
 )
 
+const uniqueViolationCode = "23505"
+
+var ErrCategoryExists = errors.New("category already exists")
+
 type Storage struct {
 	pool *pgxpool.Pool
+	// actorID and source attribute the changes to the audit log, see WithActor.
+	actorID int64
+	source  string
 }
 
 func NewStorage(ctx context.Context, postgresDsn string) (*Storage, error) {
@@ -39,42 +49,194 @@ func (s *Storage) Close() {
 }
 
 func (s *Storage) AddUser(user model.User) error {
+	defer observe("AddUser", time.Now())
 	query := `INSERT INTO users (chat_id, username, language) VALUES ($1, $2, $3)`
 	_, err := s.pool.Exec(context.Background(), query, user.ChatID, user.Username, user.Language)
 	return err
 }
 
 func (s *Storage) GetUserByChatID(chatID int64) (model.User, error) {
-	query := `SELECT chat_id, username, language, created_at FROM users WHERE chat_id = $1`
+	defer observe("GetUserByChatID", time.Now())
+	query := `SELECT chat_id, username, language, timezone, created_at FROM users WHERE chat_id = $1`
 	u := model.User{}
-	err := s.pool.QueryRow(context.Background(), query, chatID).Scan(&u.ChatID, &u.Username, &u.Language, &u.CreatedAt)
+	err := s.pool.QueryRow(context.Background(), query, chatID).Scan(
+		&u.ChatID, &u.Username, &u.Language, &u.Timezone, &u.CreatedAt,
+	)
 	return u, err
 }
 
 func (s *Storage) AddCategory(category model.Category) error {
-	query := `INSERT INTO categories (name, chat_id) VALUES ($1, $2)`
-	_, err := s.pool.Exec(context.Background(), query, category.Name, category.ChatID)
-	return err
+	defer observe("AddCategory", time.Now())
+	query := `INSERT INTO categories (name, chat_id, parent_id) VALUES ($1, $2, NULLIF($3, 0))`
+	_, err := s.exec(context.Background(), query, category.Name, category.ChatID, category.ParentID)
+	return mapCategoryError(err)
 }
 
-func (s *Storage) RenameCategory(categoryId int64, newName string) error {
-	query := `UPDATE categories SET name = $1 WHERE id = $2`
-	_, err := s.pool.Exec(context.Background(), query, newName, categoryId)
-	return err
+// RenameCategory renames the category of the chat and journals the previous name for /undo. It returns
+// model.ErrUnknownCategory when the chat has no such category.
+func (s *Storage) RenameCategory(chatID, categoryID int64, newName string) error {
+	defer observe("RenameCategory", time.Now())
+	query := `WITH old AS (SELECT id, chat_id, name FROM categories WHERE id = $2 AND chat_id = $3 FOR UPDATE),
+                   renamed AS (UPDATE categories c SET name = $1 FROM old WHERE c.id = old.id RETURNING c.id)
+              INSERT INTO action_journal (chat_id, kind, target_id, old_value)
+              SELECT old.chat_id, ` + actionRenameCategory + `, old.id, old.name
+              FROM old
+              JOIN renamed ON renamed.id = old.id`
+	tag, err := s.exec(context.Background(), query, newName, categoryID, chatID)
+	if err != nil {
+		return mapCategoryError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrUnknownCategory
+	}
+	return nil
 }
 
+const categoryColumns = `c.id, c.name, c.chat_id, COALESCE(c.parent_id, 0), c.kind, c.icon, c.sort_order, c.created_at`
+
+// categoryUsageJoin joins the number of transactions booked to each category of the chat $1 as u.usage, and
+// categoryOrder is the order in which categories are listed: manual sort order first, then by how many
+// transactions were booked to them. MoveCategory finds neighbours in the same order.
+const (
+	categoryUsageJoin = `LEFT JOIN (SELECT category_id, COUNT(*) AS usage
+                         FROM transactions
+                         WHERE chat_id = $1
+                         GROUP BY category_id) u ON u.category_id = c.id`
+	categoryOrder = `c.sort_order, COALESCE(u.usage, 0) DESC, c.id`
+)
+
 func (s *Storage) GetCategoriesByChatID(chatID int64) ([]model.Category, error) {
-	query := `SELECT id, name FROM categories WHERE chat_id = $1`
+	defer observe("GetCategoriesByChatID", time.Now())
+	query := `SELECT ` + categoryColumns + `
+              FROM categories c
+              ` + categoryUsageJoin + `
+              WHERE c.chat_id = $1
+              ORDER BY ` + categoryOrder
 	rows, err := s.pool.Query(context.Background(), query, chatID)
 	if err != nil {
 		return nil, err
 	}
+	return collectCategories(rows)
+}
+
+// GetCategoriesByKind returns the categories usable for the transaction type, ordered by manual
+// sort order and then by how many transactions were booked to them.
+func (s *Storage) GetCategoriesByKind(chatID int64, transactionType uint8) ([]model.Category, error) {
+	defer observe("GetCategoriesByKind", time.Now())
+	query := `SELECT ` + categoryColumns + `
+              FROM categories c
+              ` + categoryUsageJoin + `
+              WHERE c.chat_id = $1
+                AND c.kind IN (0, $2)
+              ORDER BY ` + categoryOrder
+	rows, err := s.pool.Query(context.Background(), query, chatID, transactionType)
+	if err != nil {
+		return nil, err
+	}
+	return collectCategories(rows)
+}
+
+func (s *Storage) GetCategoryByID(chatID, categoryID int64) (model.Category, error) {
+	defer observe("GetCategoryByID", time.Now())
+	query := `SELECT ` + categoryColumns + ` FROM categories c WHERE c.chat_id = $1 AND c.id = $2`
+	var c model.Category
+	err := s.pool.QueryRow(context.Background(), query, chatID, categoryID).Scan(
+		&c.ID, &c.Name, &c.ChatID, &c.ParentID, &c.Kind, &c.Icon, &c.SortOrder, &c.CreatedAt,
+	)
+	return c, err
+}
+
+func (s *Storage) SetCategoryKind(chatID, categoryID int64, kind uint8) error {
+	defer observe("SetCategoryKind", time.Now())
+	query := `UPDATE categories SET kind = $1 WHERE chat_id = $2 AND id = $3`
+	_, err := s.exec(context.Background(), query, kind, chatID, categoryID)
+	return err
+}
+
+func (s *Storage) SetCategoryIcon(chatID, categoryID int64, icon string) error {
+	defer observe("SetCategoryIcon", time.Now())
+	query := `UPDATE categories SET icon = $1 WHERE chat_id = $2 AND id = $3`
+	_, err := s.exec(context.Background(), query, icon, chatID, categoryID)
+	return err
+}
+
+// MoveCategory swaps the category with its previous (up) or next sibling in the listed order. Siblings from
+// the swapped pair down are renumbered after the ones above it, so that the pair keeps its place while
+// siblings above still follow usage.
+func (s *Storage) MoveCategory(chatID, categoryID int64, up bool) error {
+	defer observe("MoveCategory", time.Now())
+	ctx := context.Background()
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT c.id, c.sort_order
+              FROM categories c
+              ` + categoryUsageJoin + `
+              WHERE c.chat_id = $1
+                AND COALESCE(c.parent_id, 0) = (SELECT COALESCE(parent_id, 0) FROM categories WHERE id = $2)
+              ORDER BY ` + categoryOrder + `
+              FOR UPDATE OF c`
+	rows, err := tx.Query(ctx, query, chatID, categoryID)
+	if err != nil {
+		return err
+	}
+	type sibling struct {
+		ID        int64
+		SortOrder int
+	}
+	siblings, err := pgx.CollectRows(rows, pgx.RowToStructByPos[sibling])
+	if err != nil {
+		return err
+	}
+
+	first := -1
+	for i, sibling := range siblings {
+		if sibling.ID != categoryID {
+			continue
+		}
+		j := i + 1
+		if up {
+			j = i - 1
+		}
+		if j >= 0 && j < len(siblings) {
+			siblings[i], siblings[j] = siblings[j], siblings[i]
+			first = min(i, j)
+		}
+		break
+	}
+	if first < 0 {
+		return tx.Commit(ctx)
+	}
+
+	position := 0
+	if first > 0 {
+		position = siblings[first-1].SortOrder
+	}
+	for _, sibling := range siblings[first:] {
+		position++
+		if sibling.SortOrder == position {
+			continue
+		}
+		query := `UPDATE categories SET sort_order = $1 WHERE id = $2`
+		if _, err := tx.Exec(ctx, query, position, sibling.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func collectCategories(rows pgx.Rows) ([]model.Category, error) {
 	defer rows.Close()
 
 	var categories []model.Category
 	for rows.Next() {
 		var c model.Category
-		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+		if err := rows.Scan(
+			&c.ID, &c.Name, &c.ChatID, &c.ParentID, &c.Kind, &c.Icon, &c.SortOrder, &c.CreatedAt,
+		); err != nil {
 			return nil, err
 		}
 		categories = append(categories, c)
@@ -83,57 +245,254 @@ func (s *Storage) GetCategoriesByChatID(chatID int64) ([]model.Category, error)
 	return categories, rows.Err()
 }
 
-func (s *Storage) AddTransaction(transaction model.Transaction) error {
-	query := `INSERT INTO transactions (chat_id, category_id, amount, transaction_type) VALUES ($1, $2, $3, $4)`
-	_, err := s.pool.Exec(
-		context.Background(),
+// AddTransaction stores the transaction, journals it for /undo and returns its ID. A zero CreatedAt means now.
+func (s *Storage) AddTransaction(transaction model.Transaction) (int64, error) {
+	defer observe("AddTransaction", time.Now())
+	query := `WITH t AS (
+                  INSERT INTO transactions
+                      (chat_id, category_id, account_id, transfer_account_id, amount, transaction_type, created_at)
+                  VALUES ($1, NULLIF($2, 0), $3, NULLIF($4, 0), $5, $6, COALESCE($7, now()))
+                  RETURNING id, chat_id),
+                   journal AS (
+                  INSERT INTO action_journal (chat_id, kind, target_id)
+                  SELECT chat_id, ` + actionAddTransaction + `, id FROM t)
+              SELECT id FROM t`
+	var createdAt *time.Time
+	if !transaction.CreatedAt.IsZero() {
+		createdAt = &transaction.CreatedAt
+	}
+	ctx := context.Background()
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(
+		ctx,
 		query,
 		transaction.ChatID,
 		transaction.CategoryID,
+		transaction.AccountID,
+		transaction.ToAccountID,
 		transaction.Amount,
 		transaction.TransactionType,
+		createdAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+// transactionColumns are the columns scanned by scanTransaction.
+const transactionColumns = `id, chat_id, COALESCE(category_id, 0), account_id, COALESCE(transfer_account_id, 0),
+                     amount, transaction_type, created_at`
+
+func scanTransaction(row pgx.Row) (model.Transaction, error) {
+	t := model.Transaction{}
+	err := row.Scan(
+		&t.ID, &t.ChatID, &t.CategoryID, &t.AccountID, &t.ToAccountID, &t.Amount, &t.TransactionType, &t.CreatedAt,
 	)
-	return err
+	if err != nil {
+		return t, err
+	}
+	t.CreatedAt = localTime(t.CreatedAt)
+	return t, nil
+}
+
+// localTime returns the wall clock of a scanned timestamp column in time.Local. pgx labels a timestamp
+// without time zone as UTC, while created_at holds server-local times and is written without the zone.
+func localTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
 }
 
+// GetTransactionsByChatID returns all transactions of the chat, oldest first.
+func (s *Storage) GetTransactionsByChatID(chatID int64) ([]model.Transaction, error) {
+	defer observe("GetTransactionsByChatID", time.Now())
+	query := `SELECT ` + transactionColumns + `
+              FROM transactions
+              WHERE chat_id = $1
+              ORDER BY created_at, id`
+	rows, err := s.pool.Query(context.Background(), query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Transaction, error) {
+		return scanTransaction(row)
+	})
+}
+
+// GetTransactions returns the transactions of the chat matching the filter, newest first.
+func (s *Storage) GetTransactions(chatID int64, filter model.TransactionFilter) ([]model.Transaction, error) {
+	defer observe("GetTransactions", time.Now())
+	query := `SELECT ` + transactionColumns + `
+              FROM transactions
+              WHERE chat_id = $1
+                AND ($2::timestamp IS NULL OR created_at >= $2)
+                AND ($3::timestamp IS NULL OR created_at < $3)
+                AND ($4 = 0 OR transaction_type = $4)
+                AND ($5 = 0 OR category_id = $5)
+                AND ($6 = 0 OR account_id = $6 OR transfer_account_id = $6)
+              ORDER BY created_at DESC, id DESC
+              LIMIT NULLIF($7, 0) OFFSET $8`
+	rows, err := s.pool.Query(context.Background(), query,
+		chatID, nullTime(filter.From), nullTime(filter.To), int16(filter.TransactionType), filter.CategoryID,
+		filter.AccountID, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Transaction, error) {
+		return scanTransaction(row)
+	})
+}
+
+// GetTransactionByID returns a transaction of the chat, or pgx.ErrNoRows.
+func (s *Storage) GetTransactionByID(chatID, transactionID int64) (model.Transaction, error) {
+	defer observe("GetTransactionByID", time.Now())
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE chat_id = $1 AND id = $2`
+	return scanTransaction(s.pool.QueryRow(context.Background(), query, chatID, transactionID))
+}
+
+// UpdateTransaction changes the type, category, account, amount and time of an income or an expense.
+// Transfers cannot be changed. It returns pgx.ErrNoRows when the chat has no such transaction.
+func (s *Storage) UpdateTransaction(transaction model.Transaction) error {
+	defer observe("UpdateTransaction", time.Now())
+	query := `UPDATE transactions
+              SET transaction_type = $3, category_id = $4, account_id = $5, amount = $6, created_at = $7
+              WHERE chat_id = $1 AND id = $2 AND transaction_type IN (1, 2)`
+	tag, err := s.exec(context.Background(), query,
+		transaction.ChatID, transaction.ID, transaction.TransactionType, transaction.CategoryID,
+		transaction.AccountID, transaction.Amount, transaction.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ValidateTransaction loads the category and the accounts of the chat and checks the transaction with
+// model.ValidateTransaction. The category is looked up within the chat, so a foreign ID is unknown.
+func (s *Storage) ValidateTransaction(transaction model.Transaction) error {
+	var category *model.Category
+	c, err := s.GetCategoryByID(transaction.ChatID, transaction.CategoryID)
+	switch {
+	case err == nil:
+		category = &c
+	case !errors.Is(err, pgx.ErrNoRows):
+		return err
+	}
+	accounts, err := s.GetAccountsByChatID(transaction.ChatID)
+	if err != nil {
+		return err
+	}
+	return model.ValidateTransaction(transaction, category, accounts)
+}
+
+// DeleteTransaction removes a transaction of the chat together with its goal contribution and journal entry.
+// It returns pgx.ErrNoRows when the chat has no such transaction.
+func (s *Storage) DeleteTransaction(chatID, transactionID int64) error {
+	defer observe("DeleteTransaction", time.Now())
+	ctx := context.Background()
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `DELETE FROM goal_contributions
+              WHERE transaction_id IN (SELECT id FROM transactions WHERE chat_id = $1 AND id = $2)`
+	if _, err := tx.Exec(ctx, query, chatID, transactionID); err != nil {
+		return err
+	}
+	query = `DELETE FROM action_journal WHERE chat_id = $1 AND kind = ` + actionAddTransaction + ` AND target_id = $2`
+	if _, err := tx.Exec(ctx, query, chatID, transactionID); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM transactions WHERE chat_id = $1 AND id = $2`, chatID, transactionID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return tx.Commit(ctx)
+}
+
+// nullTime passes a zero time as NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// GetTransactionsStatsByCategory returns the income and expense totals of every category used in the period,
+// largest first. Categories with equal sums are ordered by name and ID, so the order never changes between calls.
 func (s *Storage) GetTransactionsStatsByCategory(chatID int64, startDate, endDate time.Time) (
-	map[string]float64,
-	map[string]float64,
+	[]model.CategoryStat,
 	error,
 ) {
-	incomeCategories := make(map[string]float64)
-	expenseCategories := make(map[string]float64)
-	query := `SELECT c.name, t.transaction_type, SUM(t.amount)
+	defer observe("GetTransactionsStatsByCategory", time.Now())
+	query := `SELECT t.category_id, c.name, t.transaction_type, SUM(t.amount) AS total, count(*)
               FROM transactions t
-              JOIN categories c ON t.category_id = c.id
-              WHERE t.chat_id = $1 
-                AND t.created_at >= $2 
+                       JOIN categories c ON c.id = t.category_id
+              WHERE t.chat_id = $1
+                AND t.created_at >= $2
                 AND t.created_at < $3
-              GROUP BY c.name, t.transaction_type`
+                AND t.transaction_type IN (1, 2)
+              GROUP BY t.category_id, c.name, t.transaction_type
+              ORDER BY total DESC, c.name, t.category_id`
 
 	rows, err := s.pool.Query(context.Background(), query, chatID, startDate, endDate)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	defer rows.Close()
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.CategoryStat, error) {
+		st := model.CategoryStat{}
+		err := row.Scan(&st.CategoryID, &st.Name, &st.TransactionType, &st.Sum, &st.Count)
+		return st, err
+	})
+}
 
-	for rows.Next() {
-		var (
-			categoryName    string
-			transactionType uint8
-			amount          float64
-		)
-
-		if err := rows.Scan(&categoryName, &transactionType, &amount); err != nil {
-			return nil, nil, err
-		}
+// GetDailyExpenses sums the expenses of every day of the period that has any, ordered by day. Days are cut
+// at midnight in the given time zone; created_at holds the local time of the database server.
+func (s *Storage) GetDailyExpenses(chatID int64, startDate, endDate time.Time, timezone string) (
+	[]model.DailyAmount,
+	error,
+) {
+	defer observe("GetDailyExpenses", time.Now())
+	query := `SELECT date_trunc('day', t.created_at::timestamptz AT TIME ZONE $4)::date AS day, SUM(t.amount)
+              FROM transactions t
+              WHERE t.chat_id = $1
+                AND t.created_at >= $2
+                AND t.created_at < $3
+                AND t.transaction_type = $5
+              GROUP BY day
+              ORDER BY day`
+	rows, err := s.pool.Query(context.Background(), query,
+		chatID, startDate, endDate, timezone, model.TransactionTypeExpense)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.DailyAmount, error) {
+		d := model.DailyAmount{}
+		err := row.Scan(&d.Day, &d.Amount)
+		return d, err
+	})
+}
 
-		if transactionType == model.TransactionTypeIncome {
-			incomeCategories[categoryName] = amount
-		} else if transactionType == model.TransactionTypeExpense {
-			expenseCategories[categoryName] = amount
-		}
+func mapCategoryError(err error) error {
+	if isUniqueViolation(err) {
+		return ErrCategoryExists
 	}
+	return err
+}
 
-	return incomeCategories, expenseCategories, rows.Err()
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
 }
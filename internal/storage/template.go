@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/cupitman9/budget-bot/internal/model"
+)
+
+var ErrTemplateExists = errors.New("template already exists")
+
+// AddTemplateFromTransaction saves the category, account, amount and type of an income or expense
+// transaction of the chat as a template.
+func (s *Storage) AddTemplateFromTransaction(chatID, transactionID int64, name string) error {
+	defer observe("AddTemplateFromTransaction", time.Now())
+	query := `INSERT INTO templates (chat_id, name, category_id, account_id, amount, transaction_type)
+              SELECT t.chat_id, $3, t.category_id, t.account_id, t.amount, t.transaction_type
+              FROM transactions t
+              WHERE t.chat_id = $1 AND t.id = $2 AND t.transaction_type IN (1, 2)`
+	tag, err := s.pool.Exec(context.Background(), query, chatID, transactionID, name)
+	if isUniqueViolation(err) {
+		return ErrTemplateExists
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+const templateColumns = `t.id, t.chat_id, t.name, t.category_id, t.account_id, t.amount, t.transaction_type, c.icon`
+
+func (s *Storage) GetTemplatesByChatID(chatID int64) ([]model.Template, error) {
+	defer observe("GetTemplatesByChatID", time.Now())
+	query := `SELECT ` + templateColumns + `
+              FROM templates t
+              JOIN categories c ON c.id = t.category_id
+              WHERE t.chat_id = $1
+              ORDER BY t.id`
+	rows, err := s.pool.Query(context.Background(), query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return collectTemplates(rows)
+}
+
+func (s *Storage) GetTemplateByID(chatID, templateID int64) (model.Template, error) {
+	defer observe("GetTemplateByID", time.Now())
+	query := `SELECT ` + templateColumns + `
+              FROM templates t
+              JOIN categories c ON c.id = t.category_id
+              WHERE t.chat_id = $1 AND t.id = $2`
+	rows, err := s.pool.Query(context.Background(), query, chatID, templateID)
+	if err != nil {
+		return model.Template{}, err
+	}
+	templates, err := collectTemplates(rows)
+	if err != nil {
+		return model.Template{}, err
+	}
+	if len(templates) == 0 {
+		return model.Template{}, pgx.ErrNoRows
+	}
+	return templates[0], nil
+}
+
+func (s *Storage) DeleteTemplate(chatID, templateID int64) error {
+	defer observe("DeleteTemplate", time.Now())
+	query := `DELETE FROM templates WHERE chat_id = $1 AND id = $2`
+	_, err := s.pool.Exec(context.Background(), query, chatID, templateID)
+	return err
+}
+
+// GetLastTransaction returns the most recent income or expense of the chat, pgx.ErrNoRows when there is none.
+func (s *Storage) GetLastTransaction(chatID int64) (model.Transaction, error) {
+	defer observe("GetLastTransaction", time.Now())
+	query := `SELECT id, chat_id, category_id, account_id, amount, transaction_type, created_at
+              FROM transactions
+              WHERE chat_id = $1 AND transaction_type IN (1, 2)
+              ORDER BY created_at DESC, id DESC
+              LIMIT 1`
+	t := model.Transaction{}
+	err := s.pool.QueryRow(context.Background(), query, chatID).Scan(
+		&t.ID, &t.ChatID, &t.CategoryID, &t.AccountID, &t.Amount, &t.TransactionType, &t.CreatedAt,
+	)
+	return t, err
+}
+
+func collectTemplates(rows pgx.Rows) ([]model.Template, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Template, error) {
+		t := model.Template{}
+		err := row.Scan(
+			&t.ID, &t.ChatID, &t.Name, &t.CategoryID, &t.AccountID, &t.Amount, &t.TransactionType, &t.CategoryIcon,
+		)
+		return t, err
+	})
+}
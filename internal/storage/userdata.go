@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// userDataDeletes removes everything stored for a chat, children before the rows they reference.
+var userDataDeletes = []string{
+	`DELETE FROM goal_contributions
+     WHERE goal_id IN (SELECT id FROM goals WHERE chat_id = $1)
+        OR transaction_id IN (SELECT id FROM transactions WHERE chat_id = $1)`,
+	`DELETE FROM goals WHERE chat_id = $1`,
+	`DELETE FROM debt_repayments WHERE debt_id IN (SELECT id FROM debts WHERE chat_id = $1)`,
+	`DELETE FROM debts WHERE chat_id = $1`,
+	`DELETE FROM transactions WHERE chat_id = $1`,
+	`DELETE FROM templates WHERE chat_id = $1`,
+	`DELETE FROM categories WHERE chat_id = $1`,
+	`DELETE FROM accounts WHERE chat_id = $1`,
+	`DELETE FROM digest_settings WHERE chat_id = $1`,
+	`DELETE FROM reminder_settings WHERE chat_id = $1`,
+	`DELETE FROM action_journal WHERE chat_id = $1`,
+	`DELETE FROM api_tokens WHERE chat_id = $1`,
+	`DELETE FROM users WHERE chat_id = $1`,
+	// last, the deletes above are audited too
+	`DELETE FROM audit_log WHERE chat_id = $1`,
+}
+
+// DeleteUserData removes the user and all their data in one transaction. A block by an admin is kept.
+func (s *Storage) DeleteUserData(chatID int64) error {
+	defer observe("DeleteUserData", time.Now())
+	ctx := context.Background()
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, query := range userDataDeletes {
+		if _, err := tx.Exec(ctx, query, chatID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// DeleteTransactions removes all transactions of the chat together with their goal contributions and
+// journal entries, keeping categories, accounts, goals and settings. It returns the number of deleted
+// transactions.
+func (s *Storage) DeleteTransactions(chatID int64) (int64, error) {
+	defer observe("DeleteTransactions", time.Now())
+	ctx := context.Background()
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `DELETE FROM goal_contributions
+              WHERE transaction_id IN (SELECT id FROM transactions WHERE chat_id = $1)`
+	if _, err := tx.Exec(ctx, query, chatID); err != nil {
+		return 0, err
+	}
+	query = `DELETE FROM action_journal WHERE chat_id = $1 AND kind = ` + actionAddTransaction
+	if _, err := tx.Exec(ctx, query, chatID); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM transactions WHERE chat_id = $1`, chatID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
@@ -0,0 +1,94 @@
+package webapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cupitman9/budget-bot/internal/api"
+)
+
+// initDataMaxAge limits how long a Mini App launch may keep using the API.
+const initDataMaxAge = 24 * time.Hour
+
+var (
+	ErrInitDataInvalid = errors.New("init data signature is invalid")
+	ErrInitDataExpired = errors.New("init data is expired")
+)
+
+// ValidateInitData checks the signature of the initData string that Telegram passes to a Mini App and
+// returns the ID of the user who opened it. The signature is an HMAC-SHA256 of the sorted "key=value"
+// lines with a key derived from the bot token, see https://core.telegram.org/bots/webapps.
+func ValidateInitData(initData, botToken string, now time.Time) (int64, error) {
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return 0, ErrInitDataInvalid
+	}
+	hash := values.Get("hash")
+	if hash == "" {
+		return 0, ErrInitDataInvalid
+	}
+
+	lines := make([]string, 0, len(values))
+	for key := range values {
+		if key != "hash" {
+			lines = append(lines, key+"="+values.Get(key))
+		}
+	}
+	sort.Strings(lines)
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(botToken))
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	expected, err := hex.DecodeString(hash)
+	if err != nil || !hmac.Equal(mac.Sum(nil), expected) {
+		return 0, ErrInitDataInvalid
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return 0, ErrInitDataInvalid
+	}
+	if now.Sub(time.Unix(authDate, 0)) > initDataMaxAge {
+		return 0, ErrInitDataExpired
+	}
+
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(values.Get("user")), &user); err != nil || user.ID == 0 {
+		return 0, ErrInitDataInvalid
+	}
+	return user.ID, nil
+}
+
+// authenticate lets through requests carrying valid initData of a user the bot is open to, in the
+// "Authorization: tma <initData>" header.
+func (s *server) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		initData, ok := strings.CutPrefix(r.Header.Get("Authorization"), "tma ")
+		if !ok {
+			api.WriteError(w, http.StatusUnauthorized, "missing init data")
+			return
+		}
+		userID, err := ValidateInitData(initData, s.botToken, time.Now())
+		if err != nil {
+			api.WriteError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if !s.allows(userID) {
+			api.WriteError(w, http.StatusForbidden, "access denied")
+			return
+		}
+		next(w, api.WithUserID(r, userID))
+	}
+}
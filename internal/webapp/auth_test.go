@@ -0,0 +1,131 @@
+package webapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cupitman9/budget-bot/internal/api"
+)
+
+const testBotToken = "123456:test-token"
+
+// signInitData builds initData signed the way Telegram signs it for the bot token.
+func signInitData(values url.Values, botToken string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := make([]string, len(keys))
+	for i, key := range keys {
+		lines[i] = key + "=" + values.Get(key)
+	}
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(botToken))
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(lines, "\n")))
+
+	signed := url.Values{}
+	for key := range values {
+		signed.Set(key, values.Get(key))
+	}
+	signed.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+	return signed.Encode()
+}
+
+func initDataValues(userID int64, authDate time.Time) url.Values {
+	return url.Values{
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"user":      {`{"id":` + strconv.FormatInt(userID, 10) + `,"first_name":"Анна","language_code":"ru"}`},
+		"auth_date": {strconv.FormatInt(authDate.Unix(), 10)},
+	}
+}
+
+func TestValidateInitData(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	good := signInitData(initDataValues(42, now.Add(-time.Hour)), testBotToken)
+
+	tampered, err := url.ParseQuery(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered.Set("user", `{"id":7,"first_name":"Анна","language_code":"ru"}`)
+
+	withoutHash, _ := url.ParseQuery(good)
+	withoutHash.Del("hash")
+
+	withoutUser := initDataValues(42, now)
+	withoutUser.Del("user")
+
+	tests := []struct {
+		name     string
+		initData string
+		want     int64
+		wantErr  error
+	}{
+		{"good hash", good, 42, nil},
+		{"just signed", signInitData(initDataValues(42, now), testBotToken), 42, nil},
+		{"tampered field", tampered.Encode(), 0, ErrInitDataInvalid},
+		{"other bot", signInitData(initDataValues(42, now), "654321:other-token"), 0, ErrInitDataInvalid},
+		{"missing hash", withoutHash.Encode(), 0, ErrInitDataInvalid},
+		{"malformed hash", strings.Replace(good, "hash=", "hash=zz", 1), 0, ErrInitDataInvalid},
+		{"malformed query", "%zz", 0, ErrInitDataInvalid},
+		{"expired auth_date", signInitData(initDataValues(42, now.Add(-25*time.Hour)), testBotToken), 0, ErrInitDataExpired},
+		{"missing user", signInitData(withoutUser, testBotToken), 0, ErrInitDataInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateInitData(tt.initData, testBotToken, now)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateInitData() = %d, %v, want %d, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := &server{botToken: testBotToken, allows: func(userID int64) bool { return userID != 7 }}
+	handler := s.authenticate(func(w http.ResponseWriter, r *http.Request) {
+		api.WriteJSON(w, http.StatusOK, api.UserID(r))
+	})
+
+	now := time.Now()
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid", "tma " + signInitData(initDataValues(42, now), testBotToken), http.StatusOK},
+		{"missing header", "", http.StatusUnauthorized},
+		{"other scheme", "Bearer " + signInitData(initDataValues(42, now), testBotToken), http.StatusUnauthorized},
+		{"invalid", "tma " + signInitData(initDataValues(42, now), "654321:other-token"), http.StatusUnauthorized},
+		{"blocked user", "tma " + signInitData(initDataValues(7, now), testBotToken), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/transactions", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusOK && strings.TrimSpace(w.Body.String()) != "42" {
+				t.Errorf("user ID = %s, want 42", w.Body)
+			}
+		})
+	}
+}
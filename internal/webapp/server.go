@@ -0,0 +1,49 @@
+// Package webapp serves the Telegram Mini App dashboard: the embedded static page and the JSON API it
+// uses, which is the API of the api package authenticated with the Mini App initData.
+package webapp
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/cupitman9/budget-bot/internal/api"
+	"github.com/cupitman9/budget-bot/internal/model"
+	"github.com/cupitman9/budget-bot/internal/storage"
+)
+
+//go:embed static
+var static embed.FS
+
+type server struct {
+	botToken string
+	allows   func(userID int64) bool
+}
+
+// NewServer returns the HTTP server of the dashboard. API requests are authenticated with the Mini App
+// initData signed with botToken, and only users that allows lets through may use the API.
+func NewServer(
+	addr, botToken string,
+	storageInstance *storage.Storage,
+	allows func(userID int64) bool,
+	log *logrus.Logger,
+) *http.Server {
+	s := &server{botToken: botToken, allows: allows}
+
+	staticFS, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServerFS(staticFS))
+	api.NewHandler(storageInstance, log, model.AuditSourceWebApp).Register(mux, "/api", s.authenticate)
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
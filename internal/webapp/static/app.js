@@ -0,0 +1,233 @@
+"use strict";
+
+// The dashboard talks to the JSON API of the bot. Every request carries the signed initData that
+// Telegram passes to the Mini App; the server checks it against the bot token.
+const tg = window.Telegram.WebApp;
+tg.ready();
+tg.expand();
+
+const pageSize = 100;
+const types = {1: "Доход", 2: "Расход", 3: "Перевод"};
+const state = {categories: new Map(), accounts: new Map(), transactions: [], offset: 0};
+
+async function api(path, options = {}) {
+    const response = await fetch(path, {
+        ...options,
+        headers: {"Authorization": "tma " + tg.initData, "Content-Type": "application/json"},
+    });
+    const body = await response.json();
+    if (!response.ok) {
+        throw new Error(body.error || response.statusText);
+    }
+    return body;
+}
+
+function showError(err) {
+    document.getElementById("error").textContent = err ? "Ошибка: " + err.message : "";
+}
+
+function formatAmount(amount) {
+    return amount.toLocaleString("ru-RU", {minimumFractionDigits: 2, maximumFractionDigits: 2});
+}
+
+function categoryPath(id) {
+    const names = [];
+    for (let c = state.categories.get(id); c && names.length <= state.categories.size; c = state.categories.get(c.parent_id)) {
+        names.unshift((c.icon ? c.icon + " " : "") + c.name);
+    }
+    return names.join(" → ");
+}
+
+function filterParams() {
+    const form = new FormData(document.getElementById("filters"));
+    const params = new URLSearchParams();
+    for (const [key, value] of form) {
+        if (value) {
+            params.set(key, value);
+        }
+    }
+    return params;
+}
+
+function option(value, text) {
+    const el = document.createElement("option");
+    el.value = value;
+    el.textContent = text;
+    return el;
+}
+
+async function loadReferences() {
+    const [categories, accounts] = await Promise.all([api("/api/categories"), api("/api/accounts")]);
+    state.categories = new Map(categories.map(c => [c.id, c]));
+    state.accounts = new Map(accounts.map(a => [a.id, a]));
+
+    const filter = document.querySelector("#filters [name=category]");
+    for (const c of categories) {
+        filter.append(option(c.id, categoryPath(c.id)));
+    }
+}
+
+async function loadStats() {
+    const params = filterParams();
+    const stats = await api("/api/stats?" + new URLSearchParams({from: params.get("from") || "", to: params.get("to") || ""}));
+    document.getElementById("income").textContent = formatAmount(stats.income);
+    document.getElementById("expense").textContent = formatAmount(stats.expense);
+    drawDailyChart(stats.daily);
+    drawCategoryChart(stats.categories.filter(c => c.type === 2));
+}
+
+function drawDailyChart(days) {
+    const svg = document.getElementById("daily-chart");
+    svg.replaceChildren();
+    const max = Math.max(0, ...days.map(d => d.amount));
+    if (max === 0) {
+        return;
+    }
+    const width = 320 / days.length;
+    days.forEach((d, i) => {
+        const height = d.amount / max * 110;
+        const rect = document.createElementNS("http://www.w3.org/2000/svg", "rect");
+        rect.setAttribute("x", String(i * width + width * 0.1));
+        rect.setAttribute("y", String(120 - height));
+        rect.setAttribute("width", String(width * 0.8));
+        rect.setAttribute("height", String(height));
+        const title = document.createElementNS("http://www.w3.org/2000/svg", "title");
+        title.textContent = d.day + ": " + formatAmount(d.amount);
+        rect.append(title);
+        svg.append(rect);
+    });
+}
+
+function drawCategoryChart(categories) {
+    const chart = document.getElementById("category-chart");
+    chart.replaceChildren();
+    const max = Math.max(0, ...categories.map(c => c.sum));
+    for (const c of categories) {
+        const row = document.createElement("div");
+        row.className = "bar";
+        const name = document.createElement("div");
+        name.textContent = categoryPath(c.category_id) || c.name;
+        const bar = document.createElement("span");
+        bar.style.width = (c.sum / max * 100) + "%";
+        const amount = document.createElement("div");
+        amount.className = "amount";
+        amount.textContent = formatAmount(c.sum);
+        row.append(name, document.createElement("div"), amount);
+        row.children[1].append(bar);
+        chart.append(row);
+    }
+}
+
+async function loadTransactions(append) {
+    if (!append) {
+        state.transactions = [];
+        state.offset = 0;
+    }
+    const params = filterParams();
+    params.set("limit", String(pageSize));
+    params.set("offset", String(state.offset));
+    const page = await api("/api/transactions?" + params);
+    state.transactions.push(...page);
+    state.offset += page.length;
+    document.getElementById("more").hidden = page.length < pageSize;
+    renderTransactions();
+}
+
+function renderTransactions() {
+    const body = document.getElementById("transactions");
+    body.replaceChildren();
+    for (const t of state.transactions) {
+        const row = document.createElement("tr");
+        const account = state.accounts.get(t.account_id);
+        const cells = [
+            new Date(t.created_at).toLocaleString("ru-RU", {dateStyle: "short", timeStyle: "short"}),
+            t.type === 3 ? "→ " + (state.accounts.get(t.to_account_id)?.name ?? "") : categoryPath(t.category_id),
+            account ? account.name : "",
+            (t.type === 1 ? "+" : "") + formatAmount(t.amount),
+        ];
+        for (const text of cells) {
+            const cell = document.createElement("td");
+            cell.textContent = text;
+            row.append(cell);
+        }
+        row.lastChild.className = "amount" + (t.type === 1 ? " income" : "");
+        if (t.type !== 3) {
+            row.addEventListener("click", () => openEditor(t));
+        } else {
+            row.title = types[3];
+        }
+        body.append(row);
+    }
+}
+
+function fillCategoryOptions(select, type, selected) {
+    select.replaceChildren();
+    for (const c of state.categories.values()) {
+        if (c.kind === 0 || c.kind === type) {
+            select.append(option(c.id, categoryPath(c.id)));
+        }
+    }
+    select.value = String(selected);
+}
+
+function localDateTime(iso) {
+    const d = new Date(iso);
+    d.setMinutes(d.getMinutes() - d.getTimezoneOffset());
+    return d.toISOString().slice(0, 16);
+}
+
+function openEditor(t) {
+    const dialog = document.getElementById("edit");
+    const form = document.getElementById("edit-form");
+    document.getElementById("edit-id").textContent = "#" + t.id;
+    document.getElementById("edit-error").textContent = "";
+
+    form.elements.type.value = String(t.type);
+    fillCategoryOptions(form.elements.category_id, t.type, t.category_id);
+    form.elements.type.onchange = () => fillCategoryOptions(form.elements.category_id, Number(form.elements.type.value), 0);
+    form.elements.account_id.replaceChildren(...[...state.accounts.values()].map(a => option(a.id, a.name)));
+    form.elements.account_id.value = String(t.account_id);
+    form.elements.amount.value = String(t.amount);
+    form.elements.created_at.value = localDateTime(t.created_at);
+
+    form.onsubmit = async event => {
+        if (event.submitter?.value !== "save") {
+            return;
+        }
+        event.preventDefault();
+        try {
+            await api("/api/transactions/" + t.id, {
+                method: "PUT",
+                body: JSON.stringify({
+                    type: Number(form.elements.type.value),
+                    category_id: Number(form.elements.category_id.value),
+                    account_id: Number(form.elements.account_id.value),
+                    amount: Number(form.elements.amount.value),
+                    created_at: new Date(form.elements.created_at.value).toISOString(),
+                }),
+            });
+            dialog.close();
+            await refresh();
+        } catch (err) {
+            document.getElementById("edit-error").textContent = err.message;
+        }
+    };
+    dialog.showModal();
+}
+
+async function refresh() {
+    try {
+        showError(null);
+        await Promise.all([loadStats(), loadTransactions(false)]);
+    } catch (err) {
+        showError(err);
+    }
+}
+
+document.getElementById("filters").addEventListener("submit", event => {
+    event.preventDefault();
+    refresh();
+});
+document.getElementById("more").addEventListener("click", () => loadTransactions(true).catch(showError));
+
+loadReferences().then(refresh).catch(showError);